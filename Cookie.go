@@ -1,9 +1,176 @@
 package marionette_client
 
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SameSite is the SameSite attribute of a cookie.
+type SameSite string
+
+const (
+	SameSiteNone   SameSite = "None"
+	SameSiteLax    SameSite = "Lax"
+	SameSiteStrict SameSite = "Strict"
+)
+
+// Cookie is a WebDriver cookie. Expiry is in seconds since the Unix epoch,
+// zero for a session cookie. A Domain starting with a dot matches the
+// subdomains too.
 type Cookie struct {
-	Domain   string
-	HttpOnly bool
-	Name     string
-	Path     string
-	Value    string
+	Domain   string   `json:"domain,omitempty"`
+	HttpOnly bool     `json:"httpOnly"`
+	Name     string   `json:"name"`
+	Path     string   `json:"path,omitempty"`
+	Value    string   `json:"value"`
+	Expiry   int64    `json:"expiry,omitempty"`
+	Secure   bool     `json:"secure"`
+	SameSite SameSite `json:"sameSite,omitempty"`
+}
+
+// HTTPCookie returns the cookie as a net/http cookie.
+func (ck Cookie) HTTPCookie() *http.Cookie {
+	hc := &http.Cookie{
+		Name:     ck.Name,
+		Value:    ck.Value,
+		Path:     ck.Path,
+		Domain:   ck.Domain,
+		Secure:   ck.Secure,
+		HttpOnly: ck.HttpOnly,
+	}
+
+	if ck.Expiry != 0 {
+		hc.Expires = time.Unix(ck.Expiry, 0)
+	}
+
+	switch ck.SameSite {
+	case SameSiteNone:
+		hc.SameSite = http.SameSiteNoneMode
+	case SameSiteLax:
+		hc.SameSite = http.SameSiteLaxMode
+	case SameSiteStrict:
+		hc.SameSite = http.SameSiteStrictMode
+	}
+
+	return hc
+}
+
+// url returns the URL the cookie is sent to.
+func (ck Cookie) url() *url.URL {
+	u := &url.URL{Scheme: "http", Host: strings.TrimPrefix(ck.Domain, "."), Path: ck.Path}
+	if ck.Secure {
+		u.Scheme = "https"
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	return u
+}
+
+// NewCookieJar returns a cookie jar holding cookies, to hand a browser
+// session to a net/http client:
+//
+//	cookies, _ := client.GetCookies()
+//	jar, _ := NewCookieJar(cookies)
+//	hc := &http.Client{Jar: jar}
+func NewCookieJar(cookies []Cookie) (*cookiejar.Jar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ck := range cookies {
+		hc := ck.HTTPCookie()
+		if !strings.HasPrefix(ck.Domain, ".") {
+			hc.Domain = "" // host-only
+		}
+
+		jar.SetCookies(ck.url(), []*http.Cookie{hc})
+	}
+
+	return jar, nil
+}
+
+// WriteCookiesTxt writes cookies to w in the Netscape cookies.txt format read
+// by curl, wget and most HTTP tools.
+func WriteCookiesTxt(w io.Writer, cookies []Cookie) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "# Netscape HTTP Cookie File")
+	for _, ck := range cookies {
+		domain := ck.Domain
+		if ck.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+
+		path := ck.Path
+		if path == "" {
+			path = "/"
+		}
+
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(strings.HasPrefix(ck.Domain, ".")), path, netscapeBool(ck.Secure), ck.Expiry, ck.Name, ck.Value)
+	}
+
+	return bw.Flush()
+}
+
+// ReadCookiesTxt reads cookies in the Netscape cookies.txt format.
+func ReadCookiesTxt(r io.Reader) ([]Cookie, error) {
+	var cookies []Cookie
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimRight(s.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, "#HttpOnly_")
+		if httpOnly {
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		}
+
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		f := strings.Split(line, "\t")
+		if len(f) != 7 {
+			return nil, fmt.Errorf("cookies.txt line %d: expected 7 fields, got %d", n, len(f))
+		}
+
+		expiry, err := strconv.ParseInt(f[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cookies.txt line %d: invalid expiry %q", n, f[4])
+		}
+
+		domain := f[0]
+		if strings.EqualFold(f[1], "TRUE") && !strings.HasPrefix(domain, ".") {
+			domain = "." + domain
+		}
+
+		cookies = append(cookies, Cookie{
+			Domain:   domain,
+			HttpOnly: httpOnly,
+			Name:     f[5],
+			Path:     f[2],
+			Value:    f[6],
+			Expiry:   expiry,
+			Secure:   strings.EqualFold(f[3], "TRUE"),
+		})
+	}
+
+	return cookies, s.Err()
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+
+	return "FALSE"
 }
@@ -1 +1,334 @@
 package marionette_client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/njasm/marionette_client/keys"
+)
+
+// Origin is what pointer and wheel coordinates are relative to: Viewport,
+// PointerOrigin (the current pointer position, pointer actions only), or a
+// *WebElement (its center).
+type Origin interface{}
+
+var (
+	Viewport      Origin = "viewport"
+	PointerOrigin Origin = "pointer"
+)
+
+// MouseButton is the button of pointer down and up actions.
+type MouseButton int
+
+const (
+	LeftButton MouseButton = iota
+	MiddleButton
+	RightButton
+)
+
+// PointerType is the kind of device of a pointer input source.
+type PointerType string
+
+const (
+	Mouse PointerType = "mouse"
+	Pen   PointerType = "pen"
+	Touch PointerType = "touch"
+)
+
+// ids of the input sources used when an Action doesn't select one with On.
+const (
+	defaultKeyboard = "keyboard"
+	defaultPointer  = "mouse"
+	defaultWheel    = "wheel"
+)
+
+// Action is a single action of an input source, for Actions.Tick.
+type Action struct {
+	kind   string // "key", "pointer" or "wheel"
+	source string
+	params map[string]interface{}
+}
+
+// On returns the action for the input source with the given id, instead of
+// the default one of its kind.
+func (a Action) On(id string) Action {
+	a.source = id
+	return a
+}
+
+// KeyDown presses key, a single grapheme cluster or a constant of the keys
+// package.
+func KeyDown(key string) Action {
+	return Action{"key", defaultKeyboard, map[string]interface{}{"type": "keyDown", "value": key}}
+}
+
+// KeyUp releases key.
+func KeyUp(key string) Action {
+	return Action{"key", defaultKeyboard, map[string]interface{}{"type": "keyUp", "value": key}}
+}
+
+// PointerMove moves the pointer to x, y relative to origin in duration.
+func PointerMove(origin Origin, x, y int, duration time.Duration) Action {
+	return Action{"pointer", defaultPointer, map[string]interface{}{
+		"type":     "pointerMove",
+		"origin":   origin,
+		"x":        x,
+		"y":        y,
+		"duration": milliseconds(duration),
+	}}
+}
+
+// PointerDown presses button.
+func PointerDown(button MouseButton) Action {
+	return Action{"pointer", defaultPointer, map[string]interface{}{"type": "pointerDown", "button": button}}
+}
+
+// PointerUp releases button.
+func PointerUp(button MouseButton) Action {
+	return Action{"pointer", defaultPointer, map[string]interface{}{"type": "pointerUp", "button": button}}
+}
+
+// Scroll scrolls by deltaX, deltaY with the wheel at x, y relative to origin,
+// Viewport or a *WebElement, in duration.
+func Scroll(origin Origin, x, y, deltaX, deltaY int, duration time.Duration) Action {
+	return Action{"wheel", defaultWheel, map[string]interface{}{
+		"type":     "scroll",
+		"origin":   origin,
+		"x":        x,
+		"y":        y,
+		"deltaX":   deltaX,
+		"deltaY":   deltaY,
+		"duration": milliseconds(duration),
+	}}
+}
+
+func milliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+// inputSource is an input source of performActions, its actions one per tick.
+type inputSource struct {
+	Type       string                   `json:"type"`
+	ID         string                   `json:"id"`
+	Parameters map[string]string        `json:"parameters,omitempty"`
+	Actions    []map[string]interface{} `json:"actions"`
+}
+
+// Actions builds a chain of input actions sent with one performActions
+// command. The chain is a sequence of ticks: every method adds one or more
+// ticks, and the actions of a tick, one per input source, run in parallel.
+//
+//	err := client.Actions().Hover(menu).Pause(time.Second).Hover(item).Click().Perform()
+type Actions struct {
+	c       *Client
+	sources []*inputSource
+	ticks   int
+	err     error
+}
+
+// Actions returns an empty chain of actions performed through c.
+func (c *Client) Actions() *Actions {
+	return &Actions{c: c}
+}
+
+// Pointer declares the pointer input source id with the pointer type typ.
+// Pointer sources not declared are mice.
+func (a *Actions) Pointer(id string, typ PointerType) *Actions {
+	if s := a.source(id); s != nil {
+		if s.Type != "pointer" {
+			a.fail(fmt.Errorf("input source %q is a %v source, not a pointer", id, s.Type))
+			return a
+		}
+
+		s.Parameters["pointerType"] = string(typ)
+		return a
+	}
+
+	a.add(&inputSource{Type: "pointer", ID: id, Parameters: map[string]string{"pointerType": string(typ)}})
+	return a
+}
+
+// Tick adds a tick running actions in parallel. Input sources without an
+// action in the tick pause.
+func (a *Actions) Tick(actions ...Action) *Actions {
+	for _, act := range actions {
+		s := a.source(act.source)
+		if s == nil {
+			s = &inputSource{Type: act.kind, ID: act.source}
+			if act.kind == "pointer" {
+				s.Parameters = map[string]string{"pointerType": string(Mouse)}
+			}
+
+			a.add(s)
+		}
+
+		if s.Type != act.kind {
+			a.fail(fmt.Errorf("input source %q is a %v source, not a %v one", s.ID, s.Type, act.kind))
+			return a
+		}
+
+		if len(s.Actions) > a.ticks {
+			a.fail(fmt.Errorf("input source %q has two actions in tick %v", s.ID, a.ticks))
+			return a
+		}
+
+		s.Actions = append(s.Actions, act.params)
+	}
+
+	a.ticks++
+	a.pad()
+	return a
+}
+
+// Pause adds a tick lasting d in which every input source pauses.
+func (a *Actions) Pause(d time.Duration) *Actions {
+	if len(a.sources) == 0 {
+		a.add(&inputSource{Type: "none", ID: "none"})
+	}
+
+	for _, s := range a.sources {
+		s.Actions = append(s.Actions, map[string]interface{}{"type": "pause", "duration": milliseconds(d)})
+	}
+
+	a.ticks++
+	return a
+}
+
+// KeyDown adds a tick pressing key.
+func (a *Actions) KeyDown(key string) *Actions {
+	return a.Tick(KeyDown(key))
+}
+
+// KeyUp adds a tick releasing key.
+func (a *Actions) KeyUp(key string) *Actions {
+	return a.Tick(KeyUp(key))
+}
+
+// SendKeys adds ticks typing text, with the semantics of WebElement.SendKeys:
+// each key of keys.Split is pressed and released in turn, except modifiers,
+// which are held until keys.Null or the end of text.
+func (a *Actions) SendKeys(text string) *Actions {
+	var held []string
+	release := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			a.KeyUp(held[i])
+		}
+
+		held = held[:0]
+	}
+
+	for _, k := range keys.Split(text) {
+		switch {
+		case k == keys.Null:
+			release()
+		case keys.IsModifier(k):
+			a.KeyDown(k)
+			held = append(held, k)
+		default:
+			a.KeyDown(k).KeyUp(k)
+		}
+	}
+
+	release()
+	return a
+}
+
+// MoveTo adds a tick moving the pointer to x, y relative to origin.
+func (a *Actions) MoveTo(origin Origin, x, y int) *Actions {
+	return a.Tick(PointerMove(origin, x, y, 0))
+}
+
+// PointerDown adds a tick pressing button.
+func (a *Actions) PointerDown(button MouseButton) *Actions {
+	return a.Tick(PointerDown(button))
+}
+
+// PointerUp adds a tick releasing button.
+func (a *Actions) PointerUp(button MouseButton) *Actions {
+	return a.Tick(PointerUp(button))
+}
+
+// Click adds ticks clicking the left button where the pointer is.
+func (a *Actions) Click() *Actions {
+	return a.PointerDown(LeftButton).PointerUp(LeftButton)
+}
+
+// DoubleClick adds ticks clicking the left button twice where the pointer is.
+func (a *Actions) DoubleClick() *Actions {
+	return a.Click().Click()
+}
+
+// ContextClick adds ticks clicking the right button where the pointer is.
+func (a *Actions) ContextClick() *Actions {
+	return a.PointerDown(RightButton).PointerUp(RightButton)
+}
+
+// Hover adds a tick moving the pointer to the center of e.
+func (a *Actions) Hover(e *WebElement) *Actions {
+	return a.MoveTo(e, 0, 0)
+}
+
+// DragAndDrop adds ticks pressing the left button on the center of src,
+// moving to the center of dst and releasing the button there.
+func (a *Actions) DragAndDrop(src, dst *WebElement) *Actions {
+	return a.Hover(src).
+		PointerDown(LeftButton).
+		Tick(PointerMove(dst, 0, 0, 250*time.Millisecond)).
+		PointerUp(LeftButton)
+}
+
+// Scroll adds a tick scrolling by deltaX, deltaY at x, y relative to origin.
+func (a *Actions) Scroll(origin Origin, x, y, deltaX, deltaY int) *Actions {
+	return a.Tick(Scroll(origin, x, y, deltaX, deltaY, 0))
+}
+
+// Perform sends the chain. Keys and buttons still pressed at the end stay
+// pressed until ReleaseActions.
+func (a *Actions) Perform() error {
+	if a.err != nil {
+		return a.err
+	}
+
+	_, err := a.c.send("performActions", map[string]interface{}{"actions": a.sources})
+	return err
+}
+
+// ReleaseActions releases the keys and buttons pressed by performed actions.
+func (c *Client) ReleaseActions() error {
+	_, err := c.send("releaseActions", nil)
+	return err
+}
+
+func (a *Actions) source(id string) *inputSource {
+	for _, s := range a.sources {
+		if s.ID == id {
+			return s
+		}
+	}
+
+	return nil
+}
+
+// add adds an input source, pausing in the ticks before it was used.
+func (a *Actions) add(s *inputSource) {
+	for len(s.Actions) < a.ticks {
+		s.Actions = append(s.Actions, map[string]interface{}{"type": "pause"})
+	}
+
+	a.sources = append(a.sources, s)
+}
+
+// pad makes the input sources without an action in the last tick pause.
+func (a *Actions) pad() {
+	for _, s := range a.sources {
+		if len(s.Actions) < a.ticks {
+			s.Actions = append(s.Actions, map[string]interface{}{"type": "pause"})
+		}
+	}
+}
+
+func (a *Actions) fail(err error) {
+	if a.err == nil {
+		a.err = err
+	}
+}
@@ -0,0 +1,149 @@
+package marionette_client
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njasm/marionette_client/keys"
+)
+
+func TestActionsFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("performActions", nil)
+	s.HandleValue("releaseActions", nil)
+
+	src, dst := c.ElementFromID("e1"), c.ElementFromID("e2")
+	err := c.Actions().
+		KeyDown(keys.Control).
+		DragAndDrop(src, dst).
+		KeyUp(keys.Control).
+		Scroll(Viewport, 10, 20, 0, 300).
+		Perform()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.ReleaseActions(); err != nil {
+		t.Fatal(err)
+	}
+
+	var p struct {
+		Actions json.RawMessage
+	}
+	s.Received("performActions")[0].Decode(&p)
+
+	want := `[` +
+		`{"type":"key","id":"keyboard","actions":[{"type":"keyDown","value":"` + keys.Control + `"},{"type":"pause"},{"type":"pause"},{"type":"pause"},{"type":"pause"},{"type":"keyUp","value":"` + keys.Control + `"},{"type":"pause"}]},` +
+		`{"type":"pointer","id":"mouse","parameters":{"pointerType":"mouse"},"actions":[{"type":"pause"},` +
+		`{"duration":0,"origin":{"element-6066-11e4-a52e-4f735466cecf":"e1"},"type":"pointerMove","x":0,"y":0},` +
+		`{"button":0,"type":"pointerDown"},` +
+		`{"duration":250,"origin":{"element-6066-11e4-a52e-4f735466cecf":"e2"},"type":"pointerMove","x":0,"y":0},` +
+		`{"button":0,"type":"pointerUp"},{"type":"pause"},{"type":"pause"}]},` +
+		`{"type":"wheel","id":"wheel","actions":[{"type":"pause"},{"type":"pause"},{"type":"pause"},{"type":"pause"},{"type":"pause"},{"type":"pause"},` +
+		`{"deltaX":0,"deltaY":300,"duration":0,"origin":"viewport","type":"scroll","x":10,"y":20}]}]`
+
+	var got, expected interface{}
+	json.Unmarshal(p.Actions, &got)
+	json.Unmarshal([]byte(want), &expected)
+	gb, _ := json.Marshal(got)
+	wb, _ := json.Marshal(expected)
+	if string(gb) != string(wb) {
+		t.Fatalf("expected\n%s\ngot\n%s", wb, gb)
+	}
+
+	if len(s.Received("releaseActions")) != 1 {
+		t.Fatal("releaseActions was not sent")
+	}
+}
+
+func TestActionsTick(t *testing.T) {
+	c, _ := connect(t)
+
+	// pinch: two fingers moving apart in parallel.
+	a := c.Actions().
+		Pointer("finger1", Touch).
+		Pointer("finger2", Touch).
+		Tick(PointerMove(Viewport, 100, 100, 0).On("finger1"), PointerMove(Viewport, 110, 100, 0).On("finger2")).
+		Tick(PointerDown(LeftButton).On("finger1"), PointerDown(LeftButton).On("finger2")).
+		Tick(PointerMove(PointerOrigin, -50, 0, 100*time.Millisecond).On("finger1"), PointerMove(PointerOrigin, 50, 0, 100*time.Millisecond).On("finger2")).
+		Pause(time.Second)
+
+	if a.err != nil {
+		t.Fatal(a.err)
+	}
+
+	for _, s := range a.sources {
+		if len(s.Actions) != 4 || s.Parameters["pointerType"] != "touch" {
+			t.Fatalf("unexpected source %+v", s)
+		}
+	}
+
+	if d := a.sources[1].Actions[3]["duration"]; d != int64(1000) {
+		t.Fatalf("expected a 1s pause, got %v", d)
+	}
+
+	if err := c.Actions().Tick(KeyDown("a"), KeyDown("b")).Perform(); err == nil {
+		t.Fatal("expected an error for two actions of one source in a tick")
+	}
+
+	if err := c.Actions().Tick(KeyDown("a").On("mouse")).MoveTo(Viewport, 0, 0).Perform(); err == nil {
+		t.Fatal("expected an error for a pointer action on a key source")
+	}
+}
+
+func TestActionsSendKeys(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("performActions", nil)
+
+	if err := c.Actions().SendKeys(keys.Chord(keys.Control, "a") + "\U0001F44D\U0001F3FD" + keys.Shift + "b").Perform(); err != nil {
+		t.Fatal(err)
+	}
+
+	var p struct {
+		Actions []struct {
+			Actions []struct {
+				Type  string
+				Value string
+			}
+		}
+	}
+	s.Received("performActions")[0].Decode(&p)
+
+	var got []string
+	for _, a := range p.Actions[0].Actions {
+		got = append(got, a.Type+" "+a.Value)
+	}
+
+	want := []string{
+		"keyDown " + keys.Control, "keyDown a", "keyUp a", "keyUp " + keys.Control,
+		"keyDown \U0001F44D\U0001F3FD", "keyUp \U0001F44D\U0001F3FD",
+		"keyDown " + keys.Shift, "keyDown b", "keyUp b", "keyUp " + keys.Shift,
+	}
+
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %+q, got %+q", want, got)
+	}
+}
+
+func TestSendKeysFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("sendKeysToElement", nil)
+
+	text := "e\u0301" + keys.Enter
+	if err := c.ElementFromID("e1").SendKeys(text); err != nil {
+		t.Fatal(err)
+	}
+
+	var p struct {
+		ID    string
+		Text  string
+		Value []string
+	}
+	s.Received("sendKeysToElement")[0].Decode(&p)
+	if p.ID != "e1" || p.Text != text || len(p.Value) != 2 || p.Value[0] != "e\u0301" || p.Value[1] != keys.Enter {
+		t.Fatalf("unexpected parameters %+q", p)
+	}
+}
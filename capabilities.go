@@ -1,21 +1,375 @@
 package marionette_client
 
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Capabilities are the W3C WebDriver capabilities of a session. Requested
+// with NewSession or StartSession, and returned by Capabilities.
+//
+// Extensions holds the capabilities without a field, like "moz:debuggerAddress";
+// their names must contain a colon.
 type Capabilities struct {
-	BrowserName                   string
-	BrowserVersion                string
-	PlatformName                  string
-	PlatformVersion               string
-	SpecificationLevel            uint32
-	RaisesAccessibilityExceptions bool
-	Rotatable                     bool
-	AcceptSslCerts                bool
-	TakesElementScreenshot        bool
-	TakesScreenshot               bool
-	Proxy                         interface{}
-	Platform                      string
-	XULappId                      string
-	AppBuildId                    string
-	Device                        string
-	Version                       string
-	Command_id                    uint32
+	BrowserName               string                  `json:"browserName,omitempty"`
+	BrowserVersion            string                  `json:"browserVersion,omitempty"`
+	PlatformName              string                  `json:"platformName,omitempty"`
+	AcceptInsecureCerts       bool                    `json:"acceptInsecureCerts,omitempty"`
+	PageLoadStrategy          PageLoadStrategy        `json:"pageLoadStrategy,omitempty"`
+	Proxy                     *Proxy                  `json:"proxy,omitempty"`
+	SetWindowRect             bool                    `json:"setWindowRect,omitempty"`
+	Timeouts                  *Timeouts               `json:"timeouts,omitempty"`
+	StrictFileInteractability bool                    `json:"strictFileInteractability,omitempty"`
+	UnhandledPromptBehavior   UnhandledPromptBehavior `json:"unhandledPromptBehavior,omitempty"`
+	FirefoxOptions            *FirefoxOptions         `json:"moz:firefoxOptions,omitempty"`
+
+	// returned by Firefox.
+	Headless            bool   `json:"moz:headless,omitempty"`
+	ProcessID           int    `json:"moz:processID,omitempty"`
+	Profile             string `json:"moz:profile,omitempty"`
+	BuildID             string `json:"moz:buildID,omitempty"`
+	AccessibilityChecks bool   `json:"moz:accessibilityChecks,omitempty"`
+
+	Extensions map[string]interface{} `json:"-"`
+
+	// Deprecated: legacy capabilities, returned by Marionette before it
+	// followed WebDriver. They are not matched by StartSession.
+	PlatformVersion               string `json:"platformVersion,omitempty"`
+	SpecificationLevel            uint32 `json:"specificationLevel,omitempty"`
+	RaisesAccessibilityExceptions bool   `json:"raisesAccessibilityExceptions,omitempty"`
+	Rotatable                     bool   `json:"rotatable,omitempty"`
+	AcceptSslCerts                bool   `json:"acceptSslCerts,omitempty"`
+	TakesElementScreenshot        bool   `json:"takesElementScreenshot,omitempty"`
+	TakesScreenshot               bool   `json:"takesScreenshot,omitempty"`
+	Platform                      string `json:"platform,omitempty"`
+	XULappId                      string `json:"XULappId,omitempty"`
+	AppBuildId                    string `json:"appBuildId,omitempty"`
+	Device                        string `json:"device,omitempty"`
+	Version                       string `json:"version,omitempty"`
+	Command_id                    uint32 `json:"command_id,omitempty"`
+}
+
+// legacyCapabilities are the JSON names of the deprecated fields of
+// Capabilities.
+var legacyCapabilities = map[string]bool{
+	"platformVersion": true, "specificationLevel": true, "raisesAccessibilityExceptions": true,
+	"rotatable": true, "acceptSslCerts": true, "takesElementScreenshot": true, "takesScreenshot": true,
+	"platform": true, "XULappId": true, "appBuildId": true, "device": true, "version": true, "command_id": true,
+}
+
+// PageLoadStrategy is when navigation commands return.
+type PageLoadStrategy string
+
+const (
+	PageLoadNone   PageLoadStrategy = "none"   // right away
+	PageLoadEager  PageLoadStrategy = "eager"  // once the document is interactive
+	PageLoadNormal PageLoadStrategy = "normal" // once the document is complete
+)
+
+// UnhandledPromptBehavior is what happens to a user prompt open when a
+// command is run.
+type UnhandledPromptBehavior string
+
+const (
+	PromptDismiss          UnhandledPromptBehavior = "dismiss"
+	PromptAccept           UnhandledPromptBehavior = "accept"
+	PromptDismissAndNotify UnhandledPromptBehavior = "dismiss and notify"
+	PromptAcceptAndNotify  UnhandledPromptBehavior = "accept and notify"
+	PromptIgnore           UnhandledPromptBehavior = "ignore"
+)
+
+// Timeouts of a session, in milliseconds.
+type Timeouts struct {
+	Implicit int `json:"implicit,omitempty"`
+	PageLoad int `json:"pageLoad,omitempty"`
+	Script   int `json:"script,omitempty"`
+}
+
+// ProxyType selects how Firefox connects to the network.
+type ProxyType string
+
+const (
+	ProxyDirect     ProxyType = "direct"
+	ProxyManual     ProxyType = "manual"
+	ProxyPAC        ProxyType = "pac"
+	ProxyAutodetect ProxyType = "autodetect"
+	ProxySystem     ProxyType = "system"
+)
+
+// Proxy configuration of a session. Hosts are written host[:port].
+type Proxy struct {
+	ProxyType          ProxyType `json:"proxyType"`
+	ProxyAutoconfigURL string    `json:"proxyAutoconfigUrl,omitempty"`
+	HTTPProxy          string    `json:"httpProxy,omitempty"`
+	SSLProxy           string    `json:"sslProxy,omitempty"`
+	SocksProxy         string    `json:"socksProxy,omitempty"`
+	SocksVersion       int       `json:"socksVersion,omitempty"`
+	NoProxy            []string  `json:"noProxy,omitempty"`
+}
+
+// FirefoxOptions is the moz:firefoxOptions capability, read by geckodriver
+// and other WebDriver servers to start Firefox.
+type FirefoxOptions struct {
+	Binary  string                 `json:"binary,omitempty"`
+	Args    []string               `json:"args,omitempty"`
+	Profile string                 `json:"profile,omitempty"` // base64 encoded zip of the profile directory
+	Prefs   map[string]interface{} `json:"prefs,omitempty"`
+	Env     map[string]string      `json:"env,omitempty"`
+}
+
+// CapabilitiesRequest is the W3C capabilities of a new session: every
+// candidate merges AlwaysMatch with one entry of FirstMatch.
+type CapabilitiesRequest struct {
+	AlwaysMatch *Capabilities   `json:"alwaysMatch,omitempty"`
+	FirstMatch  []*Capabilities `json:"firstMatch,omitempty"`
+}
+
+// CapabilityError reports a capability that is invalid, that can't be merged
+// or matched, or that the session was created without. Err is
+// ErrInvalidArgument or ErrSessionNotCreated.
+type CapabilityError struct {
+	Name      string
+	Requested interface{}
+	Returned  interface{}
+	Reason    string
+	Err       error
+}
+
+func (e *CapabilityError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("marionette: capability %v: %v", e.Name, e.Reason)
+	}
+
+	return fmt.Sprintf("marionette: capability %v: requested %v, got %v", e.Name, e.Requested, e.Returned)
+}
+
+func (e *CapabilityError) Unwrap() error {
+	return e.Err
+}
+
+type capabilities Capabilities
+
+// capabilityNames are the JSON names of the fields of Capabilities.
+var capabilityNames = func() map[string]bool {
+	names := map[string]bool{}
+	t := reflect.TypeOf(Capabilities{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]; name != "-" {
+			names[name] = true
+		}
+	}
+
+	return names
+}()
+
+func (c Capabilities) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(capabilities(c))
+	if err != nil || len(c.Extensions) == 0 {
+		return b, err
+	}
+
+	m := make(map[string]interface{}, len(c.Extensions))
+	for name, v := range c.Extensions {
+		m[name] = v
+	}
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(m)
+}
+
+func (c *Capabilities) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*capabilities)(c)); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+
+	c.Extensions = nil
+	for name, v := range m {
+		if capabilityNames[name] {
+			continue
+		}
+
+		if c.Extensions == nil {
+			c.Extensions = map[string]interface{}{}
+		}
+
+		c.Extensions[name] = v
+	}
+
+	return nil
+}
+
+// Validate returns a *CapabilityError for the first invalid capability of c.
+func (c *Capabilities) Validate() error {
+	invalid := func(name string, v interface{}, reason string) error {
+		return &CapabilityError{Name: name, Requested: v, Reason: reason, Err: ErrInvalidArgument}
+	}
+
+	switch c.PageLoadStrategy {
+	case "", PageLoadNone, PageLoadEager, PageLoadNormal:
+	default:
+		return invalid("pageLoadStrategy", c.PageLoadStrategy, fmt.Sprintf("unknown strategy %q", c.PageLoadStrategy))
+	}
+
+	switch c.UnhandledPromptBehavior {
+	case "", PromptDismiss, PromptAccept, PromptDismissAndNotify, PromptAcceptAndNotify, PromptIgnore:
+	default:
+		return invalid("unhandledPromptBehavior", c.UnhandledPromptBehavior, fmt.Sprintf("unknown behavior %q", c.UnhandledPromptBehavior))
+	}
+
+	if t := c.Timeouts; t != nil && (t.Implicit < 0 || t.PageLoad < 0 || t.Script < 0) {
+		return invalid("timeouts", t, "timeouts can't be negative")
+	}
+
+	if p := c.Proxy; p != nil {
+		switch p.ProxyType {
+		case ProxyDirect, ProxyAutodetect, ProxySystem:
+		case ProxyPAC:
+			if p.ProxyAutoconfigURL == "" {
+				return invalid("proxy", p, "a pac proxy needs proxyAutoconfigUrl")
+			}
+		case ProxyManual:
+			if p.SocksProxy != "" && p.SocksVersion == 0 {
+				return invalid("proxy", p, "socksProxy needs socksVersion")
+			}
+		default:
+			return invalid("proxy", p, fmt.Sprintf("unknown proxyType %q", p.ProxyType))
+		}
+	}
+
+	for name, v := range c.Extensions {
+		if !strings.Contains(name, ":") {
+			return invalid(name, v, "unknown capability, extension capabilities are prefixed, like moz:")
+		}
+	}
+
+	return nil
+}
+
+// Merge validates r and returns its candidates, AlwaysMatch merged with each
+// FirstMatch entry in order. A capability set in both is a *CapabilityError.
+func (r *CapabilitiesRequest) Merge() ([]*Capabilities, error) {
+	always := map[string]json.RawMessage{}
+	if r.AlwaysMatch != nil {
+		if err := r.AlwaysMatch.Validate(); err != nil {
+			return nil, err
+		}
+
+		if err := remarshal(r.AlwaysMatch, &always); err != nil {
+			return nil, err
+		}
+	}
+
+	first := r.FirstMatch
+	if len(first) == 0 {
+		first = []*Capabilities{nil}
+	}
+
+	candidates := make([]*Capabilities, 0, len(first))
+	for _, fm := range first {
+		merged := make(map[string]json.RawMessage, len(always))
+		for name, v := range always {
+			merged[name] = v
+		}
+
+		if fm != nil {
+			if err := fm.Validate(); err != nil {
+				return nil, err
+			}
+
+			var m map[string]json.RawMessage
+			if err := remarshal(fm, &m); err != nil {
+				return nil, err
+			}
+
+			for name, v := range m {
+				if _, found := always[name]; found {
+					return nil, &CapabilityError{Name: name, Requested: string(v), Reason: "set in both alwaysMatch and firstMatch", Err: ErrInvalidArgument}
+				}
+
+				merged[name] = v
+			}
+		}
+
+		c := &Capabilities{}
+		if err := remarshal(merged, c); err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, c)
+	}
+
+	return candidates, nil
+}
+
+func remarshal(from, to interface{}) error {
+	b, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, to)
+}
+
+// matchCapabilities returns a *CapabilityError for the first capability of
+// requested the returned capabilities don't have. browserVersion, which is
+// matched by the server, moz:firefoxOptions, which Marionette doesn't return,
+// and the legacy capabilities are not compared.
+func matchCapabilities(requested, returned *Capabilities) error {
+	var want, got map[string]interface{}
+	if err := remarshal(requested, &want); err != nil {
+		return err
+	}
+
+	if returned != nil {
+		if err := remarshal(returned, &got); err != nil {
+			return err
+		}
+	}
+
+	for name, v := range want {
+		if name == "browserVersion" || name == "moz:firefoxOptions" || legacyCapabilities[name] {
+			continue
+		}
+
+		if !subset(v, got[name]) {
+			return &CapabilityError{Name: name, Requested: v, Returned: got[name], Err: ErrSessionNotCreated}
+		}
+	}
+
+	return nil
+}
+
+// subset reports whether got has every value of want. Strings are compared
+// without case.
+func subset(want, got interface{}) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return false
+		}
+
+		for name, v := range w {
+			if !subset(v, g[name]) {
+				return false
+			}
+		}
+
+		return true
+	case string:
+		g, ok := got.(string)
+		return ok && strings.EqualFold(w, g)
+	default:
+		return reflect.DeepEqual(want, got)
+	}
 }
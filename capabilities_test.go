@@ -0,0 +1,181 @@
+package marionette_client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func TestCapabilitiesJSON(t *testing.T) {
+	c := &Capabilities{
+		AcceptInsecureCerts: true,
+		PageLoadStrategy:    PageLoadEager,
+		Timeouts:            &Timeouts{Script: 1000},
+		Extensions:          map[string]interface{}{"moz:debuggerAddress": true},
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"acceptInsecureCerts":true,"moz:debuggerAddress":true,"pageLoadStrategy":"eager","timeouts":{"script":1000}}`
+	if string(b) != want {
+		t.Fatalf("expected %s, got %s", want, b)
+	}
+
+	var got Capabilities
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !got.AcceptInsecureCerts || got.Timeouts.Script != 1000 || got.Extensions["moz:debuggerAddress"] != true || len(got.Extensions) != 1 {
+		t.Fatalf("unexpected capabilities %#v", got)
+	}
+}
+
+func TestCapabilitiesValidate(t *testing.T) {
+	for _, c := range []*Capabilities{
+		{PageLoadStrategy: "fast"},
+		{UnhandledPromptBehavior: "shrug"},
+		{Timeouts: &Timeouts{Implicit: -1}},
+		{Proxy: &Proxy{ProxyType: ProxyPAC}},
+		{Extensions: map[string]interface{}{"debuggerAddress": true}},
+	} {
+		err := c.Validate()
+		var ce *CapabilityError
+		if !errors.As(err, &ce) || !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("expected an invalid argument *CapabilityError, got %v", err)
+		}
+	}
+
+	c := &Capabilities{PageLoadStrategy: PageLoadNone, Proxy: &Proxy{ProxyType: ProxyManual, HTTPProxy: "proxy:8080"}}
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCapabilitiesRequestMerge(t *testing.T) {
+	r := &CapabilitiesRequest{
+		AlwaysMatch: &Capabilities{AcceptInsecureCerts: true},
+		FirstMatch:  []*Capabilities{{BrowserName: "chrome"}, {PageLoadStrategy: PageLoadEager}},
+	}
+
+	candidates, err := r.Merge()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(candidates) != 2 || candidates[0].BrowserName != "chrome" || !candidates[1].AcceptInsecureCerts || candidates[1].PageLoadStrategy != PageLoadEager {
+		t.Fatalf("unexpected candidates %+v %+v", candidates[0], candidates[1])
+	}
+
+	r.FirstMatch = append(r.FirstMatch, &Capabilities{AcceptInsecureCerts: true})
+	_, err = r.Merge()
+	var ce *CapabilityError
+	if !errors.As(err, &ce) || ce.Name != "acceptInsecureCerts" {
+		t.Fatalf("expected a merge conflict on acceptInsecureCerts, got %v", err)
+	}
+}
+
+func TestStartSessionFake(t *testing.T) {
+	c, s := connect(t)
+	caps, err := c.StartSession(&CapabilitiesRequest{
+		AlwaysMatch: &Capabilities{Timeouts: &Timeouts{Script: 1000}},
+		FirstMatch:  []*Capabilities{{BrowserName: "chrome"}, {BrowserName: "firefox", PageLoadStrategy: PageLoadEager}},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.SessionID() != "00000000-0000-0000-0000-000000000000" || caps.PageLoadStrategy != PageLoadEager || caps.PlatformName != "linux" {
+		t.Fatalf("unexpected session %v %+v", c.SessionID(), caps)
+	}
+
+	var p struct {
+		Capabilities map[string]interface{}
+	}
+	s.Received("newSession")[0].Decode(&p)
+	if p.Capabilities["browserName"] != "firefox" || p.Capabilities["timeouts"] == nil {
+		t.Fatalf("expected the firefox candidate to be sent, got %v", p.Capabilities)
+	}
+
+	s.Handle("newSession", func(marionettetest.Command) (interface{}, error) {
+		return map[string]interface{}{
+			"sessionId":    "1",
+			"capabilities": map[string]interface{}{"browserName": "firefox", "acceptInsecureCerts": false},
+		}, nil
+	})
+
+	_, err = c.StartSession(&CapabilitiesRequest{AlwaysMatch: &Capabilities{AcceptInsecureCerts: true}})
+	var ce *CapabilityError
+	if !errors.As(err, &ce) || ce.Name != "acceptInsecureCerts" || !errors.Is(err, ErrSessionNotCreated) {
+		t.Fatalf("expected acceptInsecureCerts to be reported, got %v", err)
+	}
+
+	_, err = c.StartSession(&CapabilitiesRequest{AlwaysMatch: &Capabilities{BrowserName: "chrome"}})
+	if !errors.As(err, &ce) || ce.Name != "browserName" {
+		t.Fatalf("expected browserName not to match, got %v", err)
+	}
+}
+
+func TestStartSessionMismatchFake(t *testing.T) {
+	c, s := connect(t)
+
+	// Marionette changes the strategy, the script timeout and drops the
+	// extension capability
+	s.Handle("newSession", func(marionettetest.Command) (interface{}, error) {
+		return map[string]interface{}{
+			"sessionId": "1",
+			"capabilities": map[string]interface{}{
+				"browserName":      "firefox",
+				"pageLoadStrategy": "normal",
+				"timeouts":         map[string]interface{}{"implicit": 0, "pageLoad": 300000, "script": 30000},
+			},
+		}, nil
+	})
+
+	for name, requested := range map[string]*Capabilities{
+		"pageLoadStrategy":    {PageLoadStrategy: PageLoadEager},
+		"timeouts":            {Timeouts: &Timeouts{Script: 1000}},
+		"moz:webdriverClick":  {Extensions: map[string]interface{}{"moz:webdriverClick": true}},
+		"acceptInsecureCerts": {AcceptInsecureCerts: true},
+	} {
+		caps, err := c.StartSession(&CapabilitiesRequest{AlwaysMatch: requested})
+		var ce *CapabilityError
+		if !errors.As(err, &ce) || ce.Name != name || !errors.Is(err, ErrSessionNotCreated) {
+			t.Errorf("%v: expected a session not created *CapabilityError, got %#v", name, err)
+			continue
+		}
+
+		if caps == nil || caps.PageLoadStrategy != PageLoadNormal {
+			t.Errorf("%v: expected the capabilities of the session, got %+v", name, caps)
+		}
+	}
+
+	// legacy capabilities are not matched
+	if _, err := c.StartSession(&CapabilitiesRequest{AlwaysMatch: &Capabilities{AcceptSslCerts: true}}); err != nil {
+		t.Fatalf("expected legacy capabilities to be ignored, got %v", err)
+	}
+}
+
+func TestCapabilitiesFake(t *testing.T) {
+	c, s := connect(t)
+	s.Handle("getSessionCapabilities", func(marionettetest.Command) (interface{}, error) {
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{"browserName": "firefox", "moz:headless": true, "moz:shutdownTimeout": 60000},
+		}, nil
+	})
+
+	caps, err := c.Capabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if caps.BrowserName != "firefox" || !caps.Headless || caps.Extensions["moz:shutdownTimeout"] != float64(60000) {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+}
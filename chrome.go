@@ -0,0 +1,203 @@
+package marionette_client
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// WithContext sets the context to ctx, runs fn, and sets the previous context
+// back, whether fn fails or not. The context is a setting of the Marionette
+// connection: commands sent meanwhile from other goroutines run in ctx too.
+//
+//	err := client.WithContext(CHROME, func() error {
+//		_, err := client.FindElement(By(ID), "urlbar-input")
+//		return err
+//	})
+func (c *Client) WithContext(ctx Context, fn func() error) error {
+	r, err := c.Context()
+	if err != nil {
+		return err
+	}
+
+	name, err := stringValue(r)
+	if err != nil {
+		return err
+	}
+
+	previous := CONTENT
+	if name == CHROME.String() {
+		previous = CHROME
+	}
+
+	if previous != ctx {
+		if _, err := c.SetContext(ctx); err != nil {
+			return err
+		}
+	}
+
+	err = fn()
+	if previous != ctx {
+		if _, serr := c.SetContext(previous); err == nil {
+			err = serr
+		}
+	}
+
+	return err
+}
+
+// ExecuteChromeScriptInto runs script with privileges in the chrome context,
+// where Services, Ci, Cc and the browser windows are in scope, and decodes
+// its result into v like ExecuteScriptInto.
+func (c *Client) ExecuteChromeScriptInto(v interface{}, script string, args ...interface{}) error {
+	return c.WithContext(CHROME, func() error {
+		return c.ExecuteScriptInto(v, script, args...)
+	})
+}
+
+// NavigateAbout loads the about: page name, e.g. "config" or "addons", in the
+// current tab.
+func (c *Client) NavigateAbout(name string) error {
+	return c.WithContext(CONTENT, func() error {
+		_, err := c.Navigate("about:" + name)
+		return err
+	})
+}
+
+const (
+	getPrefScript = `let [name] = arguments;
+switch (Services.prefs.getPrefType(name)) {
+  case Services.prefs.PREF_BOOL: return {type: "bool", value: Services.prefs.getBoolPref(name)};
+  case Services.prefs.PREF_INT: return {type: "int", value: Services.prefs.getIntPref(name)};
+  case Services.prefs.PREF_STRING: return {type: "string", value: Services.prefs.getStringPref(name)};
+}
+return {type: ""};`
+
+	setPrefScript = `let [name, type, value] = arguments;
+switch (type) {
+  case "bool": Services.prefs.setBoolPref(name, value); break;
+  case "int": Services.prefs.setIntPref(name, value); break;
+  case "string": Services.prefs.setStringPref(name, value); break;
+}`
+
+	clearPrefScript = `Services.prefs.clearUserPref(arguments[0]);`
+)
+
+// GetPref returns the value of the preference name: a bool, an int or a
+// string, nil if it isn't set.
+func (c *Client) GetPref(name string) (interface{}, error) {
+	var d struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+
+	if err := c.ExecuteChromeScriptInto(&d, getPrefScript, name); err != nil {
+		return nil, err
+	}
+
+	var err error
+	switch d.Type {
+	case "bool":
+		var b bool
+		err = json.Unmarshal(d.Value, &b)
+		return b, err
+	case "int":
+		var i int
+		err = json.Unmarshal(d.Value, &i)
+		return i, err
+	case "string":
+		var s string
+		err = json.Unmarshal(d.Value, &s)
+		return s, err
+	}
+
+	return nil, nil
+}
+
+// SetPref sets the user value of the preference name to value, a bool, an
+// integer or a string. Integer preferences are 32 bits: a value out of the
+// int32 range is an error. The value lasts until ClearPref, or the end of the
+// profile.
+func (c *Client) SetPref(name string, value interface{}) error {
+	var typ string
+	switch value.(type) {
+	case bool:
+		typ = "bool"
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		n, ok := prefInt(value)
+		if !ok {
+			return fmt.Errorf("preference %v: %v overflows an int32", name, value)
+		}
+
+		typ = "int"
+		value = n
+	case string:
+		typ = "string"
+	default:
+		return fmt.Errorf("preference %v: unsupported value type %T", name, value)
+	}
+
+	return c.ExecuteChromeScriptInto(nil, setPrefScript, name, typ, value)
+}
+
+// prefInt returns the integer value as an int32, and whether it is in range.
+func prefInt(value interface{}) (int32, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		return int32(u), u <= math.MaxInt32
+	}
+
+	i := v.Int()
+	return int32(i), i >= math.MinInt32 && i <= math.MaxInt32
+}
+
+// ClearPref resets the preference name to its default value.
+func (c *Client) ClearPref(name string) error {
+	return c.ExecuteChromeScriptInto(nil, clearPrefScript, name)
+}
+
+// AnonymousChildren returns the anonymous children of e, the XUL or XBL
+// content of its binding. Only Firefox versions with XBL, before 72, have
+// anonymous content.
+func (e *WebElement) AnonymousChildren() ([]*WebElement, error) {
+	r, err := e.c.send("findElements", map[string]interface{}{"using": fmt.Sprint(ANON), "value": "", "element": e.Id()})
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []*WebElement
+	err = json.Unmarshal([]byte(r.Value), &elements)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, el := range elements {
+		el.c = e.c
+	}
+
+	return elements, nil
+}
+
+// AnonymousElement returns the anonymous descendant of e whose attribute name
+// is value, e.g. AnonymousElement("anonid", "input").
+func (e *WebElement) AnonymousElement(name string, value string) (*WebElement, error) {
+	r, err := e.c.send("findElement", map[string]interface{}{
+		"using":   fmt.Sprint(ANON_ATTRIBUTE),
+		"value":   map[string]string{name: value},
+		"element": e.Id(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	el := &WebElement{c: e.c}
+	err = json.Unmarshal([]byte(r.Value), el)
+	if err != nil {
+		return nil, err
+	}
+
+	return el, nil
+}
@@ -0,0 +1,126 @@
+package marionette_client
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func TestWithContextFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("getContext", "content")
+	s.HandleValue("setContext", nil)
+
+	fail := errors.New("no menu")
+	if err := c.WithContext(CHROME, func() error { return fail }); err != fail {
+		t.Fatalf("expected the error of fn, got %v", err)
+	}
+
+	if err := c.WithContext(CONTENT, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+	for _, cmd := range s.Received("setContext") {
+		var p map[string]string
+		cmd.Decode(&p)
+		values = append(values, p["value"])
+	}
+
+	if strings.Join(values, ",") != "chrome,content" || len(s.Received("getContext")) != 2 {
+		t.Fatalf("unexpected context switches %v", values)
+	}
+}
+
+func TestPrefsFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("getContext", "content")
+	s.HandleValue("setContext", nil)
+
+	for _, tc := range []struct {
+		result map[string]interface{}
+		value  interface{}
+	}{
+		{map[string]interface{}{"type": "bool", "value": true}, true},
+		{map[string]interface{}{"type": "int", "value": 3}, 3},
+		{map[string]interface{}{"type": "string", "value": "en-US"}, "en-US"},
+		{map[string]interface{}{"type": ""}, nil},
+	} {
+		s.HandleValue("executeScript", tc.result)
+		v, err := c.GetPref("some.pref")
+		if err != nil || v != tc.value {
+			t.Fatalf("expected %#v, got %#v, %v", tc.value, v, err)
+		}
+	}
+
+	s.HandleValue("executeScript", nil)
+	if err := c.SetPref("browser.startup.page", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.SetPref("some.pref", 1.5); err == nil {
+		t.Fatal("expected an error for a float value")
+	}
+
+	for _, v := range []interface{}{int64(math.MaxInt32 + 1), int64(math.MinInt32 - 1), uint32(math.MaxUint32), uint64(math.MaxUint64)} {
+		if err := c.SetPref("some.pref", v); err == nil {
+			t.Fatalf("expected an error for %T %v, out of the int32 range", v, v)
+		}
+	}
+
+	if err := c.ClearPref("browser.startup.page"); err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := s.Received("executeScript")
+	var p struct {
+		Args []interface{}
+	}
+	cmds[4].Decode(&p)
+	if len(cmds) != 6 || len(p.Args) != 3 || p.Args[1] != "int" || p.Args[2] != 3.0 {
+		t.Fatalf("unexpected setPref arguments %v", p.Args)
+	}
+
+	if err := c.SetPref("browser.startup.page", uint64(math.MaxInt32)); err != nil {
+		t.Fatal(err)
+	}
+
+	cmds = s.Received("executeScript")
+	cmds[len(cmds)-1].Decode(&p)
+	if p.Args[1] != "int" || p.Args[2] != float64(math.MaxInt32) {
+		t.Fatalf("unexpected setPref arguments %v", p.Args)
+	}
+}
+
+func TestAnonymousElementsFake(t *testing.T) {
+	c, s := connect(t)
+	s.Handle("findElements", func(cmd marionettetest.Command) (interface{}, error) {
+		return []interface{}{marionettetest.Element("a1"), marionettetest.Element("a2")}, nil
+	})
+	s.HandleValue("findElement", marionettetest.Element("a3"))
+
+	e := c.ElementFromID("e1")
+	children, err := e.AnonymousChildren()
+	if err != nil || len(children) != 2 || children[1].Id() != "a2" || children[1].c != c {
+		t.Fatalf("got %v, %v", children, err)
+	}
+
+	input, err := e.AnonymousElement("anonid", "input")
+	if err != nil || input.Id() != "a3" {
+		t.Fatalf("got %v, %v", input, err)
+	}
+
+	var p struct {
+		Using   string
+		Value   json.RawMessage
+		Element string
+	}
+	s.Received("findElement")[0].Decode(&p)
+	if p.Using != "anon attribute" || string(p.Value) != `{"anonid":"input"}` || p.Element != "e1" {
+		t.Fatalf("unexpected parameters %+v", p)
+	}
+}
@@ -1,9 +1,14 @@
 package marionette_client
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/http/cookiejar"
 	"strings"
+	"sync"
+
+	"github.com/njasm/marionette_client/keys"
 )
 
 const (
@@ -11,26 +16,63 @@ const (
 	MARIONETTE_PROTOCOL_V3 = 3
 
 	WEBDRIVER_ELEMENT_KEY = "element-6066-11e4-a52e-4f735466cecf"
+
+	// LEGACY_ELEMENT_KEY is the key of web element references sent, along
+	// with WEBDRIVER_ELEMENT_KEY, by older Marionette versions.
+	LEGACY_ELEMENT_KEY = "ELEMENT"
 )
 
 var RunningInDebugMode bool = false
 
 type session struct {
 	SessionId string
+
+	mu         sync.Mutex
+	frames     []frame // from the top-level document to the current frame
+	retryStale bool
+	relocated  func(e *WebElement, staleID string, err error)
 }
 
 type Client struct {
-	session
+	*session
 	transport Transporter
+	ctx       context.Context
 }
 
 func NewClient() *Client {
 	return &Client{
-		session{},
+		&session{},
 		&MarionetteTransport{},
+		nil,
 	}
 }
 
+// BindContext returns a shallow copy of the client whose commands are sent with
+// ctx: the deadline of ctx applies to each command and cancelling it aborts the
+// command in flight with a *CancelledError. The copy shares the connection and
+// session with c. Elements found through the copy are bound to ctx as well.
+func (c *Client) BindContext(ctx context.Context) *Client {
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+func (c *Client) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
+}
+
+func (c *Client) send(command string, values interface{}) (*Response, error) {
+	return c.transport.SendContext(c.context(), command, values)
+}
+
 func (c *Client) Transport(t Transporter) {
 	c.transport = t
 }
@@ -40,30 +82,45 @@ func (c *Client) SessionID() string {
 }
 
 func (c *Client) Connect(host string, port int) error {
-	return c.transport.Connect(host, port)
+	return c.transport.ConnectContext(c.context(), host, port)
+}
+
+// Close closes the connection to Marionette.
+func (c *Client) Close() error {
+	return c.transport.Close()
+}
+
+// Command sends an arbitrary Marionette command and returns its raw response.
+// It is meant for the commands that have no method of their own.
+func (c *Client) Command(name string, parameters interface{}) (*Response, error) {
+	return c.send(name, parameters)
 }
 
-// Send the current session's capabilities to the client.
-// Capabilities informs the client of which WebDriver features are
-// supported by Firefox and Marionette.  They are immutable for the
-// length of the session.
-// The return value is an immutable map of string keys
-// ("capabilities") to values, which may be of types boolean,
-// numerical or string.
+// ElementFromID returns the element with the given web element reference,
+// as returned by Id(), bound to the client.
+func (c *Client) ElementFromID(id string) *WebElement {
+	return &WebElement{id: id, c: c}
+}
+
+// Capabilities returns the capabilities of the current session. They
+// inform the client of which WebDriver features are supported by Firefox
+// and Marionette, and are immutable for the length of the session.
 func (c *Client) Capabilities() (*Capabilities, error) {
-	buf, err := c.transport.Send("getSessionCapabilities", nil)
+	buf, err := c.send("getSessionCapabilities", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	response := map[string]*Capabilities{"Capabilities": &Capabilities{}}
+	var response struct {
+		Capabilities *Capabilities `json:"capabilities"`
+	}
+
 	err = json.Unmarshal([]byte(buf.Value), &response)
 	if err != nil {
 		return nil, err
 	}
 
-	cap, _ := response["capabilities"]
-	return cap, nil
+	return response.Capabilities, nil
 }
 
 /////////////
@@ -72,12 +129,18 @@ func (c *Client) Capabilities() (*Capabilities, error) {
 
 // create new session
 func (c *Client) NewSession(sessionId string, cap *Capabilities) (*Response, error) {
+	if cap != nil {
+		if err := cap.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
 	data := map[string]interface{}{
 		"sessionId":    sessionId,
 		"capabilities": cap,
 	}
 
-	response, err := c.transport.Send("newSession", data)
+	response, err := c.send("newSession", data)
 	if err != nil {
 		return nil, err
 	}
@@ -90,9 +153,57 @@ func (c *Client) NewSession(sessionId string, cap *Capabilities) (*Response, err
 	return response, nil
 }
 
+// StartSession creates a session from W3C capabilities, processed as a
+// WebDriver server does: the request is validated and merged, the first
+// candidate Firefox can match is sent to Marionette, and the capabilities of
+// the new session are returned.
+//
+// A requested capability the session was created without is reported as a
+// *CapabilityError along with the returned capabilities; the session is
+// open, delete it if the capability matters.
+func (c *Client) StartSession(req *CapabilitiesRequest) (*Capabilities, error) {
+	if req == nil {
+		req = &CapabilitiesRequest{}
+	}
+
+	candidates, err := req.Merge()
+	if err != nil {
+		return nil, err
+	}
+
+	var requested *Capabilities
+	for _, candidate := range candidates {
+		if candidate.BrowserName == "" || strings.EqualFold(candidate.BrowserName, "firefox") {
+			requested = candidate
+			break
+		}
+	}
+
+	if requested == nil {
+		return nil, &CapabilityError{Name: "browserName", Requested: candidates[0].BrowserName, Returned: "firefox", Err: ErrSessionNotCreated}
+	}
+
+	response, err := c.send("newSession", map[string]interface{}{"capabilities": requested})
+	if err != nil {
+		return nil, err
+	}
+
+	var session struct {
+		SessionId    string
+		Capabilities *Capabilities `json:"capabilities"`
+	}
+
+	if err := json.Unmarshal([]byte(response.Value), &session); err != nil {
+		return nil, err
+	}
+
+	c.SessionId = session.SessionId
+	return session.Capabilities, matchCapabilities(requested, session.Capabilities)
+}
+
 //  Deletes session
 func (c *Client) DeleteSession() error {
-	_, err := c.transport.Send("deleteSession", nil)
+	_, err := c.send("deleteSession", nil)
 	if err != nil {
 		return err
 	}
@@ -105,7 +216,7 @@ func (c *Client) DeleteSession() error {
 // param number ms
 //     Time in milliseconds.
 func (c *Client) SetScriptTimeout(milliseconds int) (*Response, error) {
-	return timeouts(&c.transport, "script", milliseconds)
+	return timeouts(c, "script", milliseconds)
 }
 
 // Set timeout for searching for elements.
@@ -113,7 +224,7 @@ func (c *Client) SetScriptTimeout(milliseconds int) (*Response, error) {
 // param number ms
 //     Search timeout in milliseconds.
 func (c *Client) SetSearchTimeout(milliseconds int) (*Response, error) {
-	return timeouts(&c.transport, "implicit", milliseconds)
+	return timeouts(c, "implicit", milliseconds)
 }
 
 // Set timeout for page loading.
@@ -121,7 +232,7 @@ func (c *Client) SetSearchTimeout(milliseconds int) (*Response, error) {
 // param number ms
 //     Search timeout in milliseconds.
 func (c *Client) SetPageTimeout(milliseconds int) (*Response, error) {
-	return timeouts(&c.transport, "", milliseconds)
+	return timeouts(c, "", milliseconds)
 }
 
 // Set timeout for page loading, searching, and scripts.
@@ -130,12 +241,12 @@ func (c *Client) SetPageTimeout(milliseconds int) (*Response, error) {
 //     Type of timeout.
 // param number ms
 //     Timeout in milliseconds.
-func timeouts(transport *Transporter, typ string, milliseconds int) (*Response, error) {
+func timeouts(c *Client, typ string, milliseconds int) (*Response, error) {
 	if typ != "implicit" && typ != "script" {
 		typ = ""
 	}
 
-	response, err := (*transport).Send("timeouts", map[string]interface{}{"type": typ, "ms": milliseconds})
+	response, err := c.send("timeouts", map[string]interface{}{"type": typ, "ms": milliseconds})
 	if err != nil {
 		return nil, err
 	}
@@ -154,17 +265,18 @@ func (c *Client) Get(url string) (*Response, error) {
 
 // open url
 func (c *Client) Navigate(url string) (*Response, error) {
-	r, err := c.transport.Send("get", map[string]string{"url": url})
+	r, err := c.send("get", map[string]string{"url": url})
 	if err != nil {
 		return nil, err
 	}
 
+	c.setFrames(nil)
 	return r, nil
 }
 
 // get title
 func (c *Client) Title() (string, error) {
-	r, err := c.transport.Send("getTitle", map[string]string{})
+	r, err := c.send("getTitle", map[string]string{})
 	if err != nil {
 		return "", err
 	}
@@ -185,7 +297,7 @@ func (c *Client) CurrentUrl() (string, error) {
 
 // get current url
 func (c *Client) Url() (string, error) {
-	r, err := c.transport.Send("getCurrentUrl", nil)
+	r, err := c.send("getCurrentUrl", nil)
 	if err != nil {
 		return "", err
 	}
@@ -201,31 +313,34 @@ func (c *Client) Url() (string, error) {
 
 // refresh
 func (c *Client) Refresh() error {
-	_, err := c.transport.Send("refresh", nil)
+	_, err := c.send("refresh", nil)
 	if err != nil {
 		return err
 	}
 
+	c.setFrames(nil)
 	return nil
 }
 
 // back
 func (c *Client) Back() error {
-	_, err := c.transport.Send("goBack", nil)
+	_, err := c.send("goBack", nil)
 	if err != nil {
 		return err
 	}
 
+	c.setFrames(nil)
 	return nil
 }
 
 // forward
 func (c *Client) Forward() error {
-	_, err := c.transport.Send("goForward", nil)
+	_, err := c.send("goForward", nil)
 	if err != nil {
 		return err
 	}
 
+	c.setFrames(nil)
 	return nil
 }
 
@@ -236,7 +351,7 @@ func (c *Client) Forward() error {
 // param string level
 //     Arbitrary log level.
 func (c *Client) Log(message string, level string) (*Response, error) {
-	response, err := c.transport.Send("log", map[string]string{"value": message, "level": level})
+	response, err := c.send("log", map[string]string{"value": message, "level": level})
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +361,7 @@ func (c *Client) Log(message string, level string) (*Response, error) {
 
 //  Return all logged messages.
 func (c *Client) Logs() (*Response, error) {
-	response, err := c.transport.Send("getLogs", nil)
+	response, err := c.send("getLogs", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -261,7 +376,7 @@ func (c *Client) Logs() (*Response, error) {
 //     Name of the context to be switched to.  Must be one of "chrome" or
 //     "content".
 func (c *Client) SetContext(value Context) (*Response, error) {
-	response, err := c.transport.Send("setContext", map[string]string{"value": fmt.Sprint(value)})
+	response, err := c.send("setContext", map[string]string{"value": fmt.Sprint(value)})
 	if err != nil {
 		return nil, err
 	}
@@ -271,7 +386,7 @@ func (c *Client) SetContext(value Context) (*Response, error) {
 
 //  Gets the context of the server, either "chrome" or "content".
 func (c *Client) Context() (*Response, error) {
-	response, err := c.transport.Send("getContext", nil)
+	response, err := c.send("getContext", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -286,7 +401,7 @@ func (c *Client) Context() (*Response, error) {
 //"getWindowHandle": GeckoDriver.prototype.getWindowHandle,
 //"getCurrentWindowHandle":  GeckoDriver.prototype.getWindowHandle,  // Selenium 2 compat
 func (c *Client) CurrentWindowHandle() (string, error) {
-	r, err := c.transport.Send("getCurrentWindowHandle", nil)
+	r, err := c.send("getCurrentWindowHandle", nil)
 	if err != nil {
 		return "", err
 	}
@@ -302,7 +417,7 @@ func (c *Client) CurrentWindowHandle() (string, error) {
 //"getChromeWindowHandle": GeckoDriver.prototype.getChromeWindowHandle,
 //"getCurrentChromeWindowHandle": GeckoDriver.prototype.getChromeWindowHandle,
 func (c *Client) CurrentChromeWindowHandle() (*Response, error) {
-	r, err := c.transport.Send("getCurrentChromeWindowHandle", nil)
+	r, err := c.send("getCurrentChromeWindowHandle", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -311,7 +426,7 @@ func (c *Client) CurrentChromeWindowHandle() (*Response, error) {
 }
 
 func (c *Client) WindowHandles() ([]string, error) {
-	r, err := c.transport.Send("getWindowHandles", nil)
+	r, err := c.send("getWindowHandles", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -326,16 +441,17 @@ func (c *Client) WindowHandles() ([]string, error) {
 }
 
 func (c *Client) SwitchToWindow(name string) error {
-	_, err := c.transport.Send("switchToWindow", map[string]interface{}{"name": name})
+	_, err := c.send("switchToWindow", map[string]interface{}{"name": name})
 	if err != nil {
 		return err
 	}
 
+	c.setFrames(nil)
 	return nil
 }
 
 func (c *Client) WindowSize() (w float32, h float32, err error) {
-	r, err := c.transport.Send("getWindowSize", nil)
+	r, err := c.send("getWindowSize", nil)
 	if err != nil {
 		return w, h, err
 	}
@@ -353,7 +469,7 @@ func (c *Client) WindowSize() (w float32, h float32, err error) {
 }
 
 func (c *Client) SetWindowSize(width float32, height float32) (w float32, h float32, err error) {
-	r, err := c.transport.Send("setWindowSize", map[string]interface{}{"width": width, "height": height})
+	r, err := c.send("setWindowSize", map[string]interface{}{"width": width, "height": height})
 	if err != nil {
 		return w, h, err
 	}
@@ -371,7 +487,7 @@ func (c *Client) SetWindowSize(width float32, height float32) (w float32, h floa
 }
 
 func (c *Client) MaximizeWindow() error {
-	_, err := c.transport.Send("maximizeWindow", nil)
+	_, err := c.send("maximizeWindow", nil)
 	if err != nil {
 		return err
 	}
@@ -379,13 +495,149 @@ func (c *Client) MaximizeWindow() error {
 	return nil
 }
 
-func (c *Client) CloseWindow() (*Response, error) {
-	r, err := c.transport.Send("close", nil)
+// MinimizeWindow minimizes the current window.
+func (c *Client) MinimizeWindow() error {
+	_, err := c.send("minimizeWindow", nil)
+	return err
+}
+
+// FullscreenWindow makes the current window fullscreen.
+func (c *Client) FullscreenWindow() error {
+	_, err := c.send("fullscreenWindow", nil)
+	return err
+}
+
+// WindowRect is the position of a window on the screen and its size, in CSS
+// pixels.
+type WindowRect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// WindowRect returns the position and size of the current window.
+func (c *Client) WindowRect() (*WindowRect, error) {
+	r, err := c.send("getWindowRect", nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return r, nil
+	rect := &WindowRect{}
+	err = json.Unmarshal([]byte(r.Value), rect)
+	if err != nil {
+		return nil, err
+	}
+
+	return rect, nil
+}
+
+// WindowRectParams are the position and size SetWindowRect sets. Nil fields
+// are left out, and keep their current value.
+type WindowRectParams struct {
+	X      *int `json:"x,omitempty"`
+	Y      *int `json:"y,omitempty"`
+	Width  *int `json:"width,omitempty"`
+	Height *int `json:"height,omitempty"`
+}
+
+// SetWindowRect moves and resizes the current window, restoring it if it is
+// minimized, maximized or fullscreen, and returns its new position and size.
+func (c *Client) SetWindowRect(params WindowRectParams) (*WindowRect, error) {
+	r, err := c.send("setWindowRect", params)
+	if err != nil {
+		return nil, err
+	}
+
+	newRect := &WindowRect{}
+	err = json.Unmarshal([]byte(r.Value), newRect)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRect, nil
+}
+
+// MoveWindow moves the current window to x, y without resizing it.
+func (c *Client) MoveWindow(x, y int) (*WindowRect, error) {
+	return c.SetWindowRect(WindowRectParams{X: &x, Y: &y})
+}
+
+// ResizeWindow resizes the current window without moving it.
+func (c *Client) ResizeWindow(width, height int) (*WindowRect, error) {
+	return c.SetWindowRect(WindowRectParams{Width: &width, Height: &height})
+}
+
+// WindowType is the kind of window opened by NewWindow.
+type WindowType string
+
+const (
+	Tab    WindowType = "tab"
+	Window WindowType = "window"
+)
+
+// NewWindow opens a new tab or window, without switching to it, and returns
+// its handle. Firefox may open a tab when a window is asked for, and the other
+// way around.
+func (c *Client) NewWindow(typ WindowType) (string, error) {
+	r, err := c.send("newWindow", map[string]interface{}{"type": typ, "focus": false})
+	if err != nil {
+		return "", err
+	}
+
+	var d struct {
+		Handle string `json:"handle"`
+	}
+
+	err = json.Unmarshal([]byte(r.Value), &d)
+	if err != nil {
+		return "", err
+	}
+
+	return d.Handle, nil
+}
+
+// CloseWindow closes the current window and returns the handles of the
+// windows left open. Switch to one of them before sending other commands.
+func (c *Client) CloseWindow() ([]string, error) {
+	r, err := c.send("close", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var d []string
+	err = json.Unmarshal([]byte(r.Value), &d)
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// WithinWindow switches to the window handle, runs fn, and switches back to
+// the current window, whether fn fails or not.
+//
+//	handle, _ := client.NewWindow(Tab)
+//	err := client.WithinWindow(handle, func() error {
+//		_, err := client.Navigate("https://example.com/checkout")
+//		return err
+//	})
+func (c *Client) WithinWindow(handle string, fn func() error) error {
+	current, err := c.CurrentWindowHandle()
+	if err != nil {
+		return err
+	}
+
+	if err := c.SwitchToWindow(handle); err != nil {
+		return err
+	}
+
+	err = fn()
+	if serr := c.SwitchToWindow(current); err == nil {
+		err = serr
+	}
+
+	return err
 }
 
 ////////////
@@ -393,7 +645,7 @@ func (c *Client) CloseWindow() (*Response, error) {
 ////////////
 
 func (c *Client) ActiveFrame() (*WebElement, error) {
-	r, err := c.transport.Send("getActiveFrame", nil)
+	r, err := c.send("getActiveFrame", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -418,20 +670,145 @@ func (c *Client) SwitchToFrame(by By, value string) error {
 		return err
 	}
 
-	_, err = c.transport.Send("switchToFrame", map[string]interface{}{"element": frame.Id(), "focus": true})
+	_, err = c.send("switchToFrame", map[string]interface{}{"element": frame.Id(), "focus": true})
+	if err != nil {
+		return err
+	}
+
+	c.pushFrame(frame)
+	return nil
+}
+
+// SwitchToFrameIndex switches to the frame index of the current document,
+// in the order of window.frames.
+func (c *Client) SwitchToFrameIndex(index int) error {
+	_, err := c.send("switchToFrame", map[string]interface{}{"id": index})
+	if err != nil {
+		return err
+	}
+
+	c.pushFrame(index)
+	return nil
+}
+
+// SwitchToFrameElement switches to the frame or iframe element e.
+func (c *Client) SwitchToFrameElement(e *WebElement) error {
+	_, err := c.send("switchToFrame", map[string]interface{}{"element": e.Id()})
 	if err != nil {
 		return err
 	}
 
+	c.pushFrame(e)
+	return nil
+}
+
+// SwitchToDefaultContent switches to the top-level document of the current
+// window.
+func (c *Client) SwitchToDefaultContent() error {
+	_, err := c.send("switchToFrame", map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+
+	c.setFrames(nil)
 	return nil
 }
 
 func (c *Client) SwitchToParentFrame() error {
-	_, err := c.transport.Send("switchToParentFrame", nil)
+	_, err := c.send("switchToParentFrame", nil)
 	if err != nil {
 		return err
 	}
 
+	c.popFrame()
+	return nil
+}
+
+// WithinFrame switches to the frame element e, runs fn, and switches back to
+// the current frame, whether fn fails or not:
+//
+//	err := client.WithinFrame(editor, func() error {
+//		body, err := client.FindElement(By(TAG_NAME), "body")
+//		if err != nil {
+//			return err
+//		}
+//
+//		return body.SendKeys("hello")
+//	})
+//
+// The current frame is the chain of frames switched to through this client
+// and its BindContext copies, since the last navigation or window switch.
+// WithinFrame restores it by switching to the top-level document and back
+// down the chain. A navigation the client didn't send, e.g. caused by a click
+// or a script, leaves the chain stale: if it can't be switched down again, the
+// top-level document stays selected and the error is returned.
+func (c *Client) WithinFrame(e *WebElement, fn func() error) error {
+	chain := c.frameChain()
+	if err := c.SwitchToFrameElement(e); err != nil {
+		return err
+	}
+
+	err := fn()
+	if rerr := c.restoreFrames(chain); err == nil {
+		err = rerr
+	}
+
+	return err
+}
+
+// frame is a frame of the frame chain, a *WebElement or an int index.
+type frame interface{}
+
+func (c *Client) frameChain() []frame {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	return append([]frame(nil), c.session.frames...)
+}
+
+func (c *Client) setFrames(frames []frame) {
+	c.session.mu.Lock()
+	c.session.frames = frames
+	c.session.mu.Unlock()
+}
+
+func (c *Client) pushFrame(f frame) {
+	c.session.mu.Lock()
+	c.session.frames = append(c.session.frames, f)
+	c.session.mu.Unlock()
+}
+
+func (c *Client) popFrame() {
+	c.session.mu.Lock()
+	if n := len(c.session.frames); n > 0 {
+		c.session.frames = c.session.frames[:n-1]
+	}
+	c.session.mu.Unlock()
+}
+
+// restoreFrames switches to the top-level document, then down the frames of
+// chain. If a frame of chain is gone, it switches back to the top-level
+// document.
+func (c *Client) restoreFrames(chain []frame) error {
+	if err := c.SwitchToDefaultContent(); err != nil {
+		return err
+	}
+
+	for _, f := range chain {
+		var err error
+		switch f := f.(type) {
+		case int:
+			err = c.SwitchToFrameIndex(f)
+		case *WebElement:
+			err = c.SwitchToFrameElement(f)
+		}
+
+		if err != nil {
+			c.SwitchToDefaultContent()
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -440,8 +817,10 @@ func (c *Client) SwitchToParentFrame() error {
 /////////////
 
 // Get all cookies
+//
+// Deprecated: use GetCookies.
 func (c *Client) Cookies() (*Response, error) {
-	r, err := c.transport.Send("getCookies", nil)
+	r, err := c.send("getCookies", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -450,8 +829,11 @@ func (c *Client) Cookies() (*Response, error) {
 }
 
 // Get all cookies
+//
+// Deprecated: Marionette ignores name and returns all the cookies, use
+// GetCookie.
 func (c *Client) Cookie(name string) (*Response, error) {
-	r, err := c.transport.Send("getCookies", map[string]interface{}{"name": name})
+	r, err := c.send("getCookies", map[string]interface{}{"name": name})
 	if err != nil {
 		return nil, err
 	}
@@ -459,87 +841,150 @@ func (c *Client) Cookie(name string) (*Response, error) {
 	return r, nil
 }
 
-//////////////////
-// WEB ELEMENTS //
-//////////////////
+// GetCookies returns the cookies visible to the current page.
+func (c *Client) GetCookies() ([]Cookie, error) {
+	r, err := c.send("getCookies", nil)
+	if err != nil {
+		return nil, err
+	}
 
-func isElementEnabled(c *Client, id string) bool {
-	r, err := c.transport.Send("isElementEnabled", map[string]interface{}{"id": id})
+	var d []Cookie
+	err = json.Unmarshal([]byte(r.Value), &d)
 	if err != nil {
-		return false
+		return nil, err
 	}
 
-	return strings.Contains(r.Value, "\"value\":true")
+	return d, nil
 }
 
-func isElementSelected(c *Client, id string) bool {
-	r, err := c.transport.Send("isElementSelected", map[string]interface{}{"id": id})
+// GetCookie returns the cookie named name visible to the current page, an
+// ErrNoSuchCookie error if there is none.
+func (c *Client) GetCookie(name string) (*Cookie, error) {
+	cookies, err := c.GetCookies()
 	if err != nil {
-		return false
+		return nil, err
 	}
 
-	return strings.Contains(r.Value, "\"value\":true")
+	for i := range cookies {
+		if cookies[i].Name == name {
+			return &cookies[i], nil
+		}
+	}
+
+	return nil, &DriverError{ErrorType: ErrNoSuchCookie.Error(), Message: "no cookie named " + name}
 }
 
-func isElementDisplayed(c *Client, id string) bool {
-	r, err := c.transport.Send("isElementDisplayed", map[string]interface{}{"id": id})
-	if err != nil {
-		return false
+// AddCookie adds a cookie for the domain of the current page, which is the
+// default for an empty Domain.
+func (c *Client) AddCookie(cookie Cookie) error {
+	_, err := c.send("addCookie", map[string]interface{}{"cookie": cookie})
+	return err
+}
+
+// AddCookies adds cookies, e.g. read by ReadCookiesTxt, stopping at the first
+// one refused. Cookies can only be added for the domain of the current page.
+func (c *Client) AddCookies(cookies []Cookie) error {
+	for _, cookie := range cookies {
+		if err := c.AddCookie(cookie); err != nil {
+			return err
+		}
 	}
 
-	return strings.Contains(r.Value, "\"value\":true")
+	return nil
+}
+
+// DeleteCookie deletes the cookie named name of the current page.
+func (c *Client) DeleteCookie(name string) error {
+	_, err := c.send("deleteCookie", map[string]interface{}{"name": name})
+	return err
 }
 
-func getElementTagName(c *Client, id string) string {
-	r, err := c.transport.Send("getElementTagName", map[string]interface{}{"id": id})
+// DeleteAllCookies deletes the cookies of the current page.
+func (c *Client) DeleteAllCookies() error {
+	_, err := c.send("deleteAllCookies", nil)
+	return err
+}
+
+// CookieJar returns a cookie jar holding the cookies of the current page, for
+// a net/http client to continue the session.
+func (c *Client) CookieJar() (*cookiejar.Jar, error) {
+	cookies, err := c.GetCookies()
 	if err != nil {
-		return ""
+		return nil, err
 	}
 
-	var d = map[string]string{}
-	json.Unmarshal([]byte(r.Value), &d)
+	return NewCookieJar(cookies)
+}
+
+//////////////////
+// WEB ELEMENTS //
+//////////////////
+
+func isElementEnabled(c *Client, id string) (bool, error) {
+	r, err := c.send("isElementEnabled", map[string]interface{}{"id": id})
+	if err != nil {
+		return false, err
+	}
 
-	return d["value"]
+	return boolValue(r)
 }
 
-func getElementText(c *Client, id string) string {
-	r, err := c.transport.Send("getElementText", map[string]interface{}{"id": id})
+func isElementSelected(c *Client, id string) (bool, error) {
+	r, err := c.send("isElementSelected", map[string]interface{}{"id": id})
 	if err != nil {
-		return ""
+		return false, err
 	}
 
-	var d = map[string]string{}
-	json.Unmarshal([]byte(r.Value), &d)
+	return boolValue(r)
+}
+
+func isElementDisplayed(c *Client, id string) (bool, error) {
+	r, err := c.send("isElementDisplayed", map[string]interface{}{"id": id})
+	if err != nil {
+		return false, err
+	}
 
-	return d["value"]
+	return boolValue(r)
 }
 
-func getElementAttribute(c *Client, id string, name string) string {
-	r, err := c.transport.Send("getElementAttribute", map[string]interface{}{"id": id, "name": name})
+func getElementTagName(c *Client, id string) (string, error) {
+	r, err := c.send("getElementTagName", map[string]interface{}{"id": id})
 	if err != nil {
-		return ""
+		return "", err
 	}
 
-	var d = map[string]string{}
-	json.Unmarshal([]byte(r.Value), &d)
+	return stringValue(r)
+}
+
+func getElementText(c *Client, id string) (string, error) {
+	r, err := c.send("getElementText", map[string]interface{}{"id": id})
+	if err != nil {
+		return "", err
+	}
 
-	return d["value"]
+	return stringValue(r)
 }
 
-func getElementCssPropertyValue(c *Client, id string, property string) string {
-	r, err := c.transport.Send("getElementValueOfCssProperty", map[string]interface{}{"id": id, "propertyName": property})
+func getElementAttribute(c *Client, id string, name string) (string, error) {
+	r, err := c.send("getElementAttribute", map[string]interface{}{"id": id, "name": name})
 	if err != nil {
-		return ""
+		return "", err
 	}
 
-	var d = map[string]string{}
-	json.Unmarshal([]byte(r.Value), &d)
+	return stringValue(r)
+}
+
+func getElementCssPropertyValue(c *Client, id string, property string) (string, error) {
+	r, err := c.send("getElementValueOfCssProperty", map[string]interface{}{"id": id, "propertyName": property})
+	if err != nil {
+		return "", err
+	}
 
-	return d["value"]
+	return stringValue(r)
 }
 
 func getElementRect(c *Client, id string) (*ElementRect, error) {
-	r, err := c.transport.Send("getElementRect", map[string]interface{}{"id": id})
+	r, err := c.send("getElementRect", map[string]interface{}{"id": id})
 	if err != nil {
 		return nil, err
 	}
@@ -553,45 +998,57 @@ func getElementRect(c *Client, id string) (*ElementRect, error) {
 	return d, nil
 }
 
-func clickElement(c *Client, id string) {
-	r, err := c.transport.Send("clickElement", map[string]interface{}{"id": id})
-	if err != nil {
-		return
-	}
-
-	var d = map[string]interface{}{}
-	json.Unmarshal([]byte(r.Value), &d)
+func clickElement(c *Client, id string) error {
+	_, err := c.send("clickElement", map[string]interface{}{"id": id})
+	return err
+}
 
-	//return d
+// sendKeysToElement sends text, and the keys it is typed with for Marionette
+// versions that expect them split.
+func sendKeysToElement(c *Client, id string, text string) error {
+	_, err := c.send("sendKeysToElement", map[string]interface{}{"id": id, "text": text, "value": keySlice(text)})
+	return err
 }
 
-func sendKeysToElement(c *Client, id string, keys string) {
-	slice := make([]string, 0)
-	for _, v := range keys {
-		slice = append(slice, fmt.Sprintf("%c", v))
+func keySlice(text string) []string {
+	s := keys.Split(text)
+	if s == nil {
+		return []string{}
 	}
 
-	r, err := c.transport.Send("sendKeysToElement", map[string]interface{}{"id": id, "value": slice})
+	return s
+}
+
+func clearElement(c *Client, id string) error {
+	_, err := c.send("clearElement", map[string]interface{}{"id": id})
+	return err
+}
+
+// stringValue decodes a {"value": "..."} response. A null value is returned as
+// the empty string.
+func stringValue(r *Response) (string, error) {
+	var d map[string]*string
+	err := json.Unmarshal([]byte(r.Value), &d)
 	if err != nil {
-		return
+		return "", err
 	}
 
-	var d = map[string]interface{}{}
-	json.Unmarshal([]byte(r.Value), &d)
+	if d["value"] == nil {
+		return "", nil
+	}
 
-	//return d
+	return *d["value"], nil
 }
 
-func clearElement(c *Client, id string) {
-	r, err := c.transport.Send("clearElement", map[string]interface{}{"id": id})
+// boolValue decodes a {"value": true|false} response.
+func boolValue(r *Response) (bool, error) {
+	var d map[string]bool
+	err := json.Unmarshal([]byte(r.Value), &d)
 	if err != nil {
-		return
+		return false, err
 	}
 
-	var d = map[string]interface{}{}
-	json.Unmarshal([]byte(r.Value), &d)
-
-	//return d
+	return d["value"], nil
 }
 
 // Find elements using the indicated search strategy.
@@ -601,18 +1058,21 @@ func clearElement(c *Client, id string) {
 // param string value
 //     Value the client is looking for.
 func (c *Client) FindElements(by By, value string) ([]*WebElement, error) {
-	return findElements(c, by, value, nil)
+	return findElements(c, by, value, nil, "")
 }
 
-func findElements(c *Client, by By, value string, startNode *string) ([]*WebElement, error) {
+// findElements looks up the elements within the element parent, whose current
+// reference is id, or within the document when parent is nil. The elements
+// remember how they were found, to be located again once stale.
+func findElements(c *Client, by By, value string, parent *WebElement, id string) ([]*WebElement, error) {
 	var params map[string]interface{}
-	if startNode == nil || *startNode == "" {
+	if id == "" {
 		params = map[string]interface{}{"using": fmt.Sprint(by), "value": value}
 	} else {
-		params = map[string]interface{}{"using": fmt.Sprint(by), "value": value, "element": *startNode}
+		params = map[string]interface{}{"using": fmt.Sprint(by), "value": value, "element": id}
 	}
 
-	response, err := c.transport.Send("findElements", params)
+	response, err := c.send("findElements", params)
 	if err != nil {
 		return nil, err
 	}
@@ -624,8 +1084,14 @@ func findElements(c *Client, by By, value string, startNode *string) ([]*WebElem
 	}
 
 	var e []*WebElement
-	for _, v := range d {
-		e = append(e, &WebElement{c: c, id: v[WEBDRIVER_ELEMENT_KEY]})
+	for i, v := range d {
+		id, found := v[WEBDRIVER_ELEMENT_KEY]
+		if !found {
+			id = v[LEGACY_ELEMENT_KEY]
+		}
+
+		loc := &locator{by: by, value: value, parent: parent, index: i}
+		e = append(e, &WebElement{c: c, id: id, loc: loc})
 	}
 
 	return e, nil
@@ -640,23 +1106,24 @@ func findElements(c *Client, by By, value string, startNode *string) ([]*WebElem
 // @param {string} value
 //     Value the client is looking for.
 func (c *Client) FindElement(by By, value string) (*WebElement, error) {
-	return findElement(c, by, value, nil)
+	return findElement(c, by, value, nil, "")
 }
 
-func findElement(c *Client, by By, value string, startNode *string) (*WebElement, error) {
+// findElement looks up an element like findElements.
+func findElement(c *Client, by By, value string, parent *WebElement, id string) (*WebElement, error) {
 	var params map[string]string
-	if startNode == nil || *startNode == "" {
+	if id == "" {
 		params = map[string]string{"using": fmt.Sprint(by), "value": value}
 	} else {
-		params = map[string]string{"using": fmt.Sprint(by), "value": value, "element": *startNode}
+		params = map[string]string{"using": fmt.Sprint(by), "value": value, "element": id}
 	}
 
-	response, err := c.transport.Send("findElement", params)
+	response, err := c.send("findElement", params)
 	if err != nil {
 		return nil, err
 	}
 
-	var e = &WebElement{c: c}
+	var e = &WebElement{c: c, loc: &locator{by: by, value: value, parent: parent, index: -1}}
 	err = json.Unmarshal([]byte(response.Value), &e)
 	if err != nil {
 		return nil, err
@@ -673,7 +1140,7 @@ func takeScreenshot(c *Client, startNode *string) (string, error) {
 		params = map[string]string{"id": *startNode}
 	}
 
-	r, err := c.transport.Send("takeScreenshot", params)
+	r, err := c.send("takeScreenshot", params)
 	if err != nil {
 		return "", err
 	}
@@ -686,7 +1153,7 @@ func takeScreenshot(c *Client, startNode *string) (string, error) {
 ///////////////////////
 
 func (c *Client) PageSource() (*Response, error) {
-	response, err := c.transport.Send("getPageSource", nil)
+	response, err := c.send("getPageSource", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -702,7 +1169,43 @@ func (c *Client) ExecuteScript(script string, args []interface{}, timeout uint,
 
 	parameters["newSandbox"] = newSandbox
 
-	response, err := c.transport.Send("executeScript", parameters)
+	response, err := c.send("executeScript", parameters)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// ExecuteScriptInto runs script with args, available as arguments[i], and
+// decodes its result into v, which must be a pointer or nil. *WebElement
+// arguments are sent as web element references. Elements returned, directly or
+// inside arrays and objects, decode into *WebElement fields, and into
+// *WebElement values when decoding into interface{}, bound to c.
+// The session script timeout applies.
+func (c *Client) ExecuteScriptInto(v interface{}, script string, args ...interface{}) error {
+	return executeScriptInto(c, "executeScript", v, script, args)
+}
+
+// ExecuteAsyncScript runs script with args, and a callback as the last
+// argument, and returns the value the callback is called with. A script that
+// doesn't call it within timeout milliseconds fails with ErrScriptTimeout; an
+// exception thrown, or a promise rejected, fails with ErrJavaScript.
+//
+//	script := "let done = arguments[arguments.length - 1]; fetch(arguments[0]).then(r => done(r.status));"
+func (c *Client) ExecuteAsyncScript(script string, args []interface{}, timeout uint, newSandbox bool) (*Response, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	parameters := map[string]interface{}{}
+	parameters["scriptTimeout"] = timeout
+	parameters["script"] = script
+	parameters["args"] = args
+
+	parameters["newSandbox"] = newSandbox
+
+	response, err := c.send("executeAsyncScript", parameters)
 	if err != nil {
 		return nil, err
 	}
@@ -710,12 +1213,19 @@ func (c *Client) ExecuteScript(script string, args []interface{}, timeout uint,
 	return response, nil
 }
 
+// ExecuteAsyncScriptInto is ExecuteAsyncScript with the arguments and result
+// of ExecuteScriptInto. The session script timeout, see SetScriptTimeout,
+// applies.
+func (c *Client) ExecuteAsyncScriptInto(v interface{}, script string, args ...interface{}) error {
+	return executeScriptInto(c, "executeAsyncScript", v, script, args)
+}
+
 /////////////
 // DIALOGS //
 /////////////
 
 func (c *Client) DismissDialog() error {
-	_, err := c.transport.Send("dismissDialog", nil)
+	_, err := c.send("dismissDialog", nil)
 	if err != nil {
 		return err
 	}
@@ -724,7 +1234,7 @@ func (c *Client) DismissDialog() error {
 }
 
 func (c *Client) AcceptDialog() error {
-	_, err := c.transport.Send("acceptDialog", nil)
+	_, err := c.send("acceptDialog", nil)
 	if err != nil {
 		return err
 	}
@@ -733,24 +1243,16 @@ func (c *Client) AcceptDialog() error {
 }
 
 func (c *Client) TextFromDialog() (string, error) {
-	r, err := c.transport.Send("getTextFromDialog", nil)
+	r, err := c.send("getTextFromDialog", nil)
 	if err != nil {
 		return "", err
 	}
 
-	var d = map[string]string{}
-	json.Unmarshal([]byte(r.Value), &d)
-
-	return d["value"], nil
+	return stringValue(r)
 }
 
-func (c *Client) SendKeysToDialog(keys string) error {
-	slice := make([]string, 0)
-	for _, v := range keys {
-		slice = append(slice, fmt.Sprintf("%c", v))
-	}
-
-	_, err := c.transport.Send("sendKeysToDialog", map[string]interface{}{"value": slice})
+func (c *Client) SendKeysToDialog(text string) error {
+	_, err := c.send("sendKeysToDialog", map[string]interface{}{"text": text, "value": keySlice(text)})
 	if err != nil {
 		return err
 	}
@@ -763,7 +1265,7 @@ func (c *Client) SendKeysToDialog(keys string) error {
 ///////////////////////
 
 func (c *Client) QuitApplication() (*Response, error) {
-	r, err := c.transport.Send("quitApplication", map[string]string{"flags": "eForceQuit"})
+	r, err := c.send("quitApplication", map[string]string{"flags": "eForceQuit"})
 	if err != nil {
 		return nil, err
 	}
@@ -771,6 +1273,8 @@ func (c *Client) QuitApplication() (*Response, error) {
 	return r, nil
 }
 
+// Screenshot returns the takeScreenshot response, a base64 encoded PNG
+// wrapped in JSON. ScreenshotPNG and ScreenshotImage decode it.
 func (c *Client) Screenshot() (string, error) {
 	return takeScreenshot(c, nil)
 }
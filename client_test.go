@@ -1,530 +1,367 @@
 package marionette_client
 
 import (
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
-	"time"
-)
 
-const (
-	TARGET_URL        = "http://www.abola.pt/"
-	ID_SELECTOR       = "clubes-hp"
-	CSS_SELECTOR_LI   = "li"
-	ID_SELECTOR_INPUT = "topo_txtPesquisa"
-	TIMEOUT           = 10000 // milliseconds
+	"github.com/njasm/marionette_client/marionettetest"
 )
 
-var client *Client
-
-func init() {
-	client = NewClient()
-	client.Transport(&MarionetteTransport{})
-	RunningInDebugMode = true
-}
-
-func TestNewSession(t *testing.T) {
-	err := client.Connect("", 0)
-	if err != nil {
-		t.Error(err)
-	}
-	t.Log("got here")
-	r, err := client.NewSession("", nil)
-	if err != nil {
-		t.Error(err)
+func TestNewSessionFake(t *testing.T) {
+	c, s := connect(t)
+	if _, err := c.NewSession("", nil); err != nil {
+		t.Fatal(err)
 	}
 
-	t.Log(r.Value)
-}
-
-// working
-func TestGetSessionID(t *testing.T) {
-	if client.SessionId != client.SessionID() {
-		t.Fatalf("SessionId differ...")
+	if c.SessionID() != "00000000-0000-0000-0000-000000000000" {
+		t.Fatalf("unexpected session id %q", c.SessionID())
 	}
 
-	t.Log("session is : ", client.SessionId)
-}
-
-func TestGetPage(t *testing.T) {
-	r, err := client.Navigate(TARGET_URL)
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if len(s.Received("newSession")) != 1 {
+		t.Fatalf("expected one newSession command, got %#v", s.Commands())
 	}
-
-	t.Log(r.Value)
 }
 
-func TestCurrentUrl(t *testing.T) {
-	url, err := client.CurrentUrl()
-	if err != nil {
-		t.Fatalf("%#v", err)
-	}
+func TestNavigationFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("get", nil)
+	s.HandleValue("getTitle", "Example Domain")
+	s.HandleValue("getCurrentUrl", "http://example.com/")
 
-	if url != TARGET_URL {
-		t.Fatalf("Current Url %v not equal to target url %v", url, TARGET_URL)
+	if _, err := c.Navigate("http://example.com/"); err != nil {
+		t.Fatal(err)
 	}
 
-}
-
-func TestGetCookies(t *testing.T) {
-	r, err := client.Cookies()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	var p map[string]string
+	s.Received("get")[0].Decode(&p)
+	if p["url"] != "http://example.com/" {
+		t.Fatalf("unexpected parameters %v", p)
 	}
 
-	t.Log(r.Value)
-}
-
-func TestGetCookie(t *testing.T) {
-	r, err := client.Cookie("abolaCookie")
-	if err != nil {
-		t.Fatalf("%#v", err)
+	title, err := c.Title()
+	if err != nil || title != "Example Domain" {
+		t.Fatalf("got %q, %v", title, err)
 	}
 
-	t.Log(r.Value)
-}
-
-//func TestConnectWithActiveConnection(t *testing.T) {
-//	err := client.Connect("", 0)
-//	if err == nil {
-//		t.Fatalf("%#v", err)
-//	}
-//
-//	t.Log("No Error..")
-//}
-
-// working
-func TestGetSessionCapabilities(t *testing.T) {
-	r, err := client.Capabilities()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	url, err := c.Url()
+	if err != nil || url != "http://example.com/" {
+		t.Fatalf("got %q, %v", url, err)
 	}
-
-	t.Log(r.BrowserName)
 }
 
-// working
-func TestScreenshot(t *testing.T) {
-	_, err := client.Screenshot()
+func TestFindElementFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("findElement", marionettetest.Element("list"))
+	s.Handle("findElements", func(cmd marionettetest.Command) (interface{}, error) {
+		return []interface{}{marionettetest.Element("a"), marionettetest.Element("b")}, nil
+	})
+	s.HandleValue("getElementText", "hello")
+
+	e, err := c.FindElement(By(ID), "list")
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	//this print ise a problem for travis builds, since it can surpass the 4 MB of log size.
-	// don't print the base64 encoded image.
-	//println(base64encoded)
-}
-
-// working
-func TestLog(t *testing.T) {
-	r, err := client.Log("message testing", "warning")
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if e.Id() != "list" {
+		t.Fatalf("unexpected element id %q", e.Id())
 	}
 
-	t.Log(r.Value)
-}
-
-// working
-func TestGetLogs(t *testing.T) {
-	r, err := client.Logs()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if text, err := e.Text(); err != nil || text != "hello" {
+		t.Fatalf("got %q, %v", text, err)
 	}
 
-	t.Log(r.Value)
-}
-
-func TestSetContext(t *testing.T) {
-	r, err := client.SetContext(Context(CHROME))
+	items, err := e.FindElements(By(TAG_NAME), "li")
 	if err != nil {
-		t.Fatalf("%#v", err)
+		t.Fatal(err)
 	}
 
-	t.Log(r.Value)
-
-	r, err = client.SetContext(Context(CONTENT))
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if len(items) != 2 || items[0].Id() != "a" || items[1].Id() != "b" {
+		t.Fatalf("unexpected elements %#v", items)
 	}
 
-	t.Log(r.Value)
-}
-
-func TestGetContext(t *testing.T) {
-	r, err := client.Context()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	var p map[string]string
+	s.Received("findElements")[0].Decode(&p)
+	if p["using"] != "tag name" || p["value"] != "li" || p["element"] != "list" {
+		t.Fatalf("unexpected parameters %v", p)
 	}
-
-	t.Log(r.Value)
 }
 
-func TestGetPageSource(t *testing.T) {
-	r, err := client.SetContext(Context(CHROME))
-	if err != nil {
-		t.Fatalf("%#v", err)
-	}
+func TestDriverErrorFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleError("findElement", "no such element", "Unable to locate element: #missing")
 
-	t.Log(r.Value)
-
-	r, err = client.SetContext(Context(CONTENT))
-	if err != nil {
-		t.Fatalf("%#v", err)
+	_, err := c.FindElement(By(CSS_SELECTOR), "#missing")
+	de, ok := err.(*DriverError)
+	if !ok {
+		t.Fatalf("expected a *DriverError, got %#v", err)
 	}
 
-	t.Log(r.Value)
-
-}
-
-func TestSetScriptTimout(t *testing.T) {
-	r, err := client.SetScriptTimeout(TIMEOUT)
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if de.ErrorType != "no such element" || de.Message != "Unable to locate element: #missing" {
+		t.Fatalf("unexpected error %#v", de)
 	}
-
-	t.Log(r.Value)
 }
 
-func TestSetPageTimout(t *testing.T) {
-	r, err := client.SetPageTimeout(TIMEOUT)
-	if err != nil {
-		t.Fatalf("%#v", err)
+func TestWebElementErrorsFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("findElement", marionettetest.Element("gone"))
+	s.HandleValue("isElementDisplayed", true)
+	s.HandleValue("getElementAttribute", nil)
+	for _, name := range []string{"clickElement", "getElementText", "isElementEnabled"} {
+		s.HandleError(name, "stale element reference", "The element reference of gone is stale")
 	}
 
-	t.Log(r.Value)
-}
-
-func TestSetSearchTimout(t *testing.T) {
-	r, err := client.SetSearchTimeout(TIMEOUT)
+	e, err := c.FindElement(By(ID), "gone")
 	if err != nil {
-		t.Fatalf("%#v", err)
+		t.Fatal(err)
 	}
 
-	t.Log(r.Value)
-}
-
-func TestPageSource(t *testing.T) {
-	_, err := client.PageSource()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if displayed, err := e.Displayed(); err != nil || !displayed {
+		t.Fatalf("got %v, %v", displayed, err)
 	}
-}
 
-func TestExecuteScriptWithoutFunction(t *testing.T) {
-	script := "return (document.readyState == 'complete');"
-	args := []interface{}{}
-	r, err := client.ExecuteScript(script, args, 1000, false)
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if value, err := e.Attribute("missing"); err != nil || value != "" {
+		t.Fatalf("got %q, %v", value, err)
 	}
 
-	t.Log(r.Value)
-}
-
-func TestExecuteScript(t *testing.T) {
-	script := "function testMyGoMarionetteClient() { return 'yes'; } return testMyGoMarionetteClient();"
-	args := []interface{}{}
-	r, err := client.ExecuteScript(script, args, 1000, false)
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if err := e.Click(); !errors.Is(err, ErrStaleElementReference) {
+		t.Fatalf("expected a stale element error from Click, got %#v", err)
 	}
 
-	t.Log(r.Value)
-}
-
-func TestExecuteScriptWithArgs(t *testing.T) {
-	script := "function testMyGoMarionetteClientArgs(a, b) { return a + b; }; return testMyGoMarionetteClientArgs(arguments[0], arguments[1]);"
-	args := []interface{}{1, 3}
-	r, err := client.ExecuteScript(script, args, 1000, false)
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if _, err := e.Text(); !errors.Is(err, ErrStaleElementReference) {
+		t.Fatalf("expected a stale element error from Text, got %#v", err)
 	}
 
-	t.Log(r.Value)
-}
-
-func TestGetTitle(t *testing.T) {
-	title, err := client.Title()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if _, err := e.Enabled(); !errors.Is(err, ErrStaleElementReference) {
+		t.Fatalf("expected a stale element error from Enabled, got %#v", err)
 	}
-
-	t.Log(title)
-
 }
-func TestFindElement(t *testing.T) {
-	element, err := client.FindElement(By(ID), ID_SELECTOR)
-	if err != nil {
-		t.Fatalf("%#v", err)
-	}
-
-	t.Log(element.Id())
-	t.Log(element.Enabled())
-	t.Log(element.Selected())
-	t.Log(element.Displayed())
-	t.Log(element.TagName())
-	t.Log(element.Text())
-	t.Log(element.Attribute("id"))
-	t.Log(element.CssValue("text-decoration"))
-	rect, err := element.Rect()
-	if err != nil {
-		t.Fatalf("%#v", err)
-	}
 
-	t.Log(rect)
+func TestExecuteScriptFake(t *testing.T) {
+	c, s := connect(t)
+	s.Handle("executeScript", func(cmd marionettetest.Command) (interface{}, error) {
+		var p struct{ Args []int }
+		cmd.Decode(&p)
+		return marionettetest.Value(p.Args[0] + p.Args[1]), nil
+	})
 
-	// size
-	w, h, err := element.Location()
+	r, err := c.ExecuteScript("return arguments[0] + arguments[1];", []interface{}{1, 3}, 1000, false)
 	if err != nil {
-		t.Fatalf("%#v", err)
+		t.Fatal(err)
 	}
 
-	t.Logf("width: %f, height: %f", w, h)
-
-	//location
-	x, y, err := element.Size()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	var d map[string]int
+	if err := json.Unmarshal([]byte(r.Value), &d); err != nil || d["value"] != 4 {
+		t.Fatalf("unexpected result %v, %v", r.Value, err)
 	}
+}
 
-	t.Logf("x: %f, y: %f", x, y)
+func TestWindowHandlesFake(t *testing.T) {
+	c, s := connect(t)
+	s.Handle("getWindowHandles", func(cmd marionettetest.Command) (interface{}, error) {
+		return []string{"1", "2"}, nil
+	})
+	s.HandleValue("switchToWindow", nil)
 
-	// screenshot of node element
-	_, err = element.Screenshot()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	handles, err := c.WindowHandles()
+	if err != nil || len(handles) != 2 {
+		t.Fatalf("got %v, %v", handles, err)
 	}
 
-	collection, err := element.FindElements(By(CSS_SELECTOR), CSS_SELECTOR_LI)
-	if 18 != len(collection) {
-		t.FailNow()
+	if err := c.SwitchToWindow(handles[1]); err != nil {
+		t.Fatal(err)
 	}
 
-	t.Logf("%T %#v", collection, collection)
-}
-
-func TestSendKeys(t *testing.T) {
-	e, err := client.FindElement(By(ID), ID_SELECTOR_INPUT)
-	if err != nil {
-		t.Fatalf("%#v", err)
+	var p map[string]string
+	s.Received("switchToWindow")[0].Decode(&p)
+	if p["name"] != "2" {
+		t.Fatalf("unexpected parameters %v", p)
 	}
-
-	e.SendKeys("teste")
 }
 
-func TestFindElements(t *testing.T) {
-	elements, err := client.FindElements(By(CSS_SELECTOR), CSS_SELECTOR_LI)
-	if err != nil {
-		t.Fatalf("%#v", err)
-	}
+func TestWindowRectFake(t *testing.T) {
+	c, s := connect(t)
+	rect := map[string]interface{}{"x": 10, "y": 20, "width": 800, "height": 600}
+	s.Handle("getWindowRect", func(cmd marionettetest.Command) (interface{}, error) {
+		return rect, nil
+	})
+	s.Handle("setWindowRect", func(cmd marionettetest.Command) (interface{}, error) {
+		return rect, nil
+	})
+	s.HandleValue("minimizeWindow", nil)
+	s.HandleValue("fullscreenWindow", nil)
 
-	t.Log(len(elements))
-}
-
-func TestCurrentChromeWindowHandle(t *testing.T) {
-	r, err := client.CurrentChromeWindowHandle()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	r, err := c.WindowRect()
+	if err != nil || *r != (WindowRect{X: 10, Y: 20, Width: 800, Height: 600}) {
+		t.Fatalf("got %+v, %v", r, err)
 	}
 
-	t.Log(r.Value)
-}
-
-func TestWindowHandles(t *testing.T) {
-	w, err := client.CurrentWindowHandle()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if _, err := c.MoveWindow(0, 0); err != nil {
+		t.Fatal(err)
 	}
 
-	t.Log(w)
-
-	r, err := client.WindowHandles()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if _, err := c.ResizeWindow(1024, 768); err != nil {
+		t.Fatal(err)
 	}
 
-	for _, w := range r {
-		err := client.SwitchToWindow(w)
-		if err != nil {
-			t.Fatalf("%#v", err)
-		}
-
-		time.Sleep(time.Duration(time.Second))
+	x, y, w, h := 5, 5, 1024, 768
+	if _, err := c.SetWindowRect(WindowRectParams{X: &x, Y: &y, Width: &w, Height: &h}); err != nil {
+		t.Fatal(err)
 	}
 
-	// return to original window.
-	client.SwitchToWindow(w)
-}
-
-func TestNavigatorMethods(t *testing.T) {
-	client.SetContext(Context(CONTENT))
-	url1 := "https://www.google.pt/"
-	url2 := "https://www.bing.com/"
-
-	client.Navigate(url1)
-	sleep := time.Duration(2) * time.Second
-	time.Sleep(sleep)
-
-	client.Navigate(url2)
-	time.Sleep(sleep)
-
-	client.Back()
-	client.Refresh()
-	time.Sleep(sleep)
+	if err := c.MinimizeWindow(); err != nil {
+		t.Fatal(err)
+	}
 
-	firstUrl, err := client.CurrentUrl()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if err := c.FullscreenWindow(); err != nil {
+		t.Fatal(err)
 	}
 
-	if firstUrl != url1 {
-		t.Fatalf("Expected url %v - received url %v", url1, firstUrl[0:len(url1)])
+	var move, resize, both map[string]interface{}
+	s.Received("setWindowRect")[0].Decode(&move)
+	s.Received("setWindowRect")[1].Decode(&resize)
+	s.Received("setWindowRect")[2].Decode(&both)
+	if len(move) != 2 || move["x"] != 0.0 || move["y"] != 0.0 {
+		t.Fatalf("expected a move to leave the size out, got %v", move)
 	}
 
-	client.Forward()
-	secondUrl, err := client.CurrentUrl()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if len(resize) != 2 || resize["width"] != 1024.0 || resize["height"] != 768.0 {
+		t.Fatalf("expected a resize to leave the position out, got %v", resize)
 	}
 
-	if secondUrl != url2 {
-		t.Fatalf("Expected url %v - received url %v", url2, secondUrl[:len(url2)])
+	if len(both) != 4 || both["x"] != 5.0 || both["height"] != 768.0 {
+		t.Fatalf("unexpected parameters %v", both)
 	}
 }
 
-func TestWait(t *testing.T) {
-	client.SetContext(Context(CONTENT))
-	client.Navigate("http://www.w3schools.com/ajax/tryit.asp?filename=tryajax_get")
+func TestNewWindowFake(t *testing.T) {
+	c, s := connect(t)
+	s.Handle("newWindow", func(cmd marionettetest.Command) (interface{}, error) {
+		return map[string]string{"handle": "2", "type": "tab"}, nil
+	})
+	s.HandleValue("getCurrentWindowHandle", "1")
+	s.HandleValue("switchToWindow", nil)
+	s.Handle("close", func(cmd marionettetest.Command) (interface{}, error) {
+		return []string{"1"}, nil
+	})
 
-	timeout := time.Duration(10) * time.Second
-	condition := ElementIsPresent(By(ID), "stackH")
-	ok, v, err := Wait(client).For(timeout).Until(condition)
-
-	if err != nil || !ok {
-		t.Fatalf("%#v", err)
+	handle, err := c.NewWindow(Tab)
+	if err != nil || handle != "2" {
+		t.Fatalf("got %v, %v", handle, err)
 	}
 
-	v.Click()
+	fail := errors.New("checkout failed")
+	err = c.WithinWindow(handle, func() error {
+		handles, err := c.CloseWindow()
+		if err != nil || len(handles) != 1 || handles[0] != "1" {
+			t.Fatalf("got %v, %v", handles, err)
+		}
 
-	err = client.SwitchToFrame(By(ID), "iframeResult")
-	if err != nil {
-		t.Fatalf("%#v", err)
+		return fail
+	})
+	if err != fail {
+		t.Fatalf("expected the error of fn, got %v", err)
 	}
 
-	e, err := client.FindElement(By(TAG_NAME), "button")
-	if err != nil {
-		t.Fatalf("%#v", err)
+	var names []string
+	for _, cmd := range s.Commands() {
+		var p map[string]string
+		cmd.Decode(&p)
+		names = append(names, cmd.Name+p["name"])
 	}
 
-	e.Click()
+	if strings.Join(names, ",") != "newWindow,getCurrentWindowHandle,switchToWindow2,close,switchToWindow1" {
+		t.Fatalf("unexpected commands %v", names)
+	}
 }
 
-func TestAlert(t *testing.T) {
-	client.Get("http://www.abola.pt")
-	var text string = "marionette is cool or what?"
-	var script string = "alert('" + text + "');"
-	args := []interface{}{}
-	r, err := client.ExecuteScript(script, args, 1000, false)
-	if err != nil {
-		t.Fatalf("%#v", err)
-	}
+func TestFramesFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("switchToFrame", nil)
+	s.HandleValue("switchToParentFrame", nil)
+	s.HandleValue("get", nil)
 
-	textFromdialog, err := client.TextFromDialog()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	outer, inner := c.ElementFromID("outer"), c.ElementFromID("inner")
+	if err := c.SwitchToFrameIndex(1); err != nil {
+		t.Fatal(err)
 	}
 
-	if textFromdialog != text {
-		t.Fatalf("Text in dialog differ. expected: %v, textfromdialog: %v", text, textFromdialog)
-	}
+	fail := errors.New("not found")
+	err := c.WithinFrame(outer, func() error {
+		return c.WithinFrame(inner, func() error {
+			if err := c.SwitchToParentFrame(); err != nil {
+				return err
+			}
 
-	err = client.AcceptDialog()
-	if err != nil {
-		t.Fatalf("%#v", err)
+			return fail
+		})
+	})
+	if err != fail {
+		t.Fatalf("expected the error of fn, got %v", err)
 	}
 
-	script = "prompt('" + text + "');"
-	r, err = client.ExecuteScript(script, args, 1000, false)
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if _, err := c.Navigate("about:blank"); err != nil {
+		t.Fatal(err)
 	}
 
-	err = client.SendKeysToDialog("yeah!")
-	if err != nil {
-		t.Fatalf("%#v", err)
+	if err := c.WithinFrame(outer, func() error { return nil }); err != nil {
+		t.Fatal(err)
 	}
 
-	time.Sleep(time.Duration(5) * time.Second)
-
-	err = client.DismissDialog()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	var switches []string
+	for _, cmd := range s.Commands() {
+		var p map[string]interface{}
+		cmd.Decode(&p)
+		switch {
+		case cmd.Name != "switchToFrame":
+			switches = append(switches, cmd.Name)
+		case p["element"] != nil:
+			switches = append(switches, p["element"].(string))
+		case p["id"] != nil:
+			switches = append(switches, "#1")
+		default:
+			switches = append(switches, "top")
+		}
 	}
 
-	t.Log(r.Value)
-}
-
-func TestNotPresent(t *testing.T) {
-	client.SwitchToParentFrame()
-	client.ActiveFrame()
-
-	timeout := time.Duration(10) * time.Second
-	condition := ElementIsNotPresent(By(ID), "non-existing-element")
-	ok, _, _ := Wait(client).For(timeout).Until(condition)
-
-	if !ok {
-		t.Fatal("Element Was Found in ElementIsNotPresent test.")
+	want := "#1,outer,inner,switchToParentFrame,top,#1,outer,top,#1,get,outer,top"
+	if strings.Join(switches, ",") != want {
+		t.Fatalf("expected %v, got %v", want, strings.Join(switches, ","))
 	}
 }
 
-func TestWindowSize(t *testing.T) {
-	w, h, err := client.WindowSize()
-	if err != nil {
-		t.Fatalf("%#v", err)
-	}
-
-	t.Logf("w: %v, h: %v", w, h)
+func TestFramesStaleChainFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("switchToFrame", nil)
 
-	var newW float32 = w / 2
-	var newH float32 = h / 2
-
-	w, h, err = client.SetWindowSize(newW, newH)
-	if err != nil {
-		t.Fatalf("%#v", err)
+	outer, inner := c.ElementFromID("outer"), c.ElementFromID("inner")
+	if err := c.SwitchToFrameElement(outer); err != nil {
+		t.Fatal(err)
 	}
 
-	t.Logf("new w: %v, new h: %v", w, h)
+	// a click navigates the page: the outer frame is gone once fn returns
+	err := c.WithinFrame(inner, func() error {
+		s.Handle("switchToFrame", func(cmd marionettetest.Command) (interface{}, error) {
+			var p map[string]interface{}
+			cmd.Decode(&p)
+			if p["element"] == "outer" {
+				return nil, &marionettetest.Error{Type: "stale element reference"}
+			}
 
-	err = client.MaximizeWindow()
-	if err != nil {
-		t.Fatalf("%#v", err)
-	}
-}
+			return marionettetest.Value(nil), nil
+		})
 
-// working - if called before other tests all hell will break loose
-func TestCloseWindow(t *testing.T) {
-	r, err := client.CloseWindow()
-	if err != nil {
-		t.Fatalf("%#v", err)
+		return nil
+	})
+	if !errors.Is(err, ErrStaleElementReference) {
+		t.Fatalf("expected the replay to fail, got %v", err)
 	}
 
-	t.Log(r.Value)
-}
-
-// working - if called before other tests all hell will break loose
-func TestDeleteSession(t *testing.T) {
-	err := client.DeleteSession()
-	if err != nil {
-		t.Fatalf("%#v", err)
+	cmds := s.Received("switchToFrame")
+	var last map[string]interface{}
+	cmds[len(cmds)-1].Decode(&last)
+	if len(last) != 0 || len(c.frameChain()) != 0 {
+		t.Fatalf("expected the top-level document to be selected, got %v, %v", last, c.frameChain())
 	}
 }
-
-// working
-//func TestQuitApplication(t *testing.T) {
-//	r, err := client.QuitApplication()
-//	if err != nil {
-//		t.Fatalf("%#v", err)
-//	}
-//
-//	t.Log(r.Value)
-//}
@@ -0,0 +1,31 @@
+// Command webdriverd serves the W3C WebDriver protocol on top of Marionette.
+//
+// Start Firefox with -marionette, then point any WebDriver client at
+// http://localhost:4444:
+//
+//	webdriverd -addr :4444 -marionette-port 2828
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/njasm/marionette_client/webdriverd"
+)
+
+func main() {
+	addr := flag.String("addr", ":4444", "address to serve WebDriver on")
+	host := flag.String("marionette-host", "127.0.0.1", "host Marionette listens on")
+	port := flag.Int("marionette-port", 2828, "port Marionette listens on")
+	flag.Parse()
+
+	s := webdriverd.NewServer(*host, *port)
+
+	log.Printf("serving WebDriver on %v, Marionette at %v:%v", *addr, *host, *port)
+	err := http.ListenAndServe(*addr, s)
+
+	// log.Fatal skips deferred calls: close the sessions first.
+	s.Close()
+	log.Fatal(err)
+}
@@ -1,62 +1,136 @@
+// Package command lists the endpoints of the W3C WebDriver protocol.
+//
+// Paths are templates whose variables are written {session_id},
+// {element_id}, {name} and {property_name}, the syntax used by
+// net/http.ServeMux patterns.
 package command
 
-
 const (
-	NewSession      = "/session"
-	DeleteSession   = "/session/{session_id}"
-	Get             = DeleteSession + "/url"
-	GetCurrentURL   = Get
+	NewSession              = "/session"
+	DeleteSession           = "/session/{session_id}"
+	Status                  = "/status"
+	GetTimeouts             = DeleteSession + "/timeouts"
+	SetTimeouts             = GetTimeouts
+	Get                     = DeleteSession + "/url"
+	GetCurrentURL           = Get
+	Back                    = DeleteSession + "/back"
+	Forward                 = DeleteSession + "/forward"
+	Refresh                 = DeleteSession + "/refresh"
+	GetTitle                = DeleteSession + "/title"
+	GetWindowHandle         = DeleteSession + "/window"
+	CloseWindow             = GetWindowHandle
+	SwitchToWindow          = GetWindowHandle
+	GetWindowHandles        = GetWindowHandle + "/handles"
+	NewWindow               = GetWindowHandle + "/new"
+	GetWindowRect           = GetWindowHandle + "/rect"
+	SetWindowRect           = GetWindowRect
+	MaximizeWindow          = GetWindowHandle + "/maximize"
+	MinimizeWindow          = GetWindowHandle + "/minimize"
+	FullscreenWindow        = GetWindowHandle + "/fullscreen"
+	GetWindowSize           = GetWindowHandle + "/size"
+	SetWindowSize           = GetWindowSize
+	SwitchToFrame           = DeleteSession + "/frame"
+	SwitchToParentFrame     = SwitchToFrame + "/parent"
+	FindElement             = DeleteSession + "/element"
+	FindElements            = DeleteSession + "/elements"
+	GetActiveElement        = FindElement + "/active"
+	element                 = FindElement + "/{element_id}"
+	FindElementFromElement  = element + "/element"
+	FindElementsFromElement = element + "/elements"
+	IsElementSelected       = element + "/selected"
+	GetElementAttribute     = element + "/attribute/{name}"
+	GetElementProperty      = element + "/property/{name}"
+	GetElementCSSValue      = element + "/css/{property_name}"
+	GetElementText          = element + "/text"
+	GetElementTagName       = element + "/name"
+	GetElementRect          = element + "/rect"
+	IsElementEnabled        = element + "/enabled"
+	ElementClick            = element + "/click"
+	ElementClear            = element + "/clear"
+	ElementSendKeys         = element + "/value"
+	TakeElementScreenshot   = element + "/screenshot"
+	GetPageSource           = DeleteSession + "/source"
+	ExecuteScript           = DeleteSession + "/execute/sync"
+	ExecuteAsyncScript      = DeleteSession + "/execute/async"
+	GetAllCookies           = DeleteSession + "/cookie"
+	GetNamedCookie          = GetAllCookies + "/{name}"
+	AddCookie               = GetAllCookies
+	DeleteCookie            = GetNamedCookie
+	DeleteAllCookies        = GetAllCookies
+	PerformActions          = DeleteSession + "/actions"
+	ReleaseActions          = PerformActions
+	DismissAlert            = DeleteSession + "/alert/dismiss"
+	AcceptAlert             = DeleteSession + "/alert/accept"
+	GetAlertText            = DeleteSession + "/alert/text"
+	SendAlertText           = GetAlertText
+	TakeScreenshot          = DeleteSession + "/screenshot"
 )
 
-/*
-POST 	/session 	New Session
-DELETE 	/session/{session id} 	Delete Session
-POST 	/session/{session id}/url 	Get
-GET 	/session/{session id}/url 	Get Current URL
-POST 	/session/{session id}/back 	Back
-POST 	/session/{session id}/forward 	Forward
-POST 	/session/{session id}/refresh 	Refresh
-GET 	/session/{session id}/title 	Get Title
-GET 	/session/{session id}/window 	Get Window Handle
-DELETE 	/session/{session id}/window 	Close Window
-POST 	/session/{session id}/window 	Switch To Window
-GET 	/session/{session id}/window/handles 	Get Window Handles
-POST 	/session/{session id}/window/fullscreen 	Fullscreen Window
-POST 	/session/{session id}/window/maximize 	Maximize Window
-POST 	/session/{session id}/window/size 	Set Window Size
-GET 	/session/{session id}/window/size 	Get Window Size
-POST 	/session/{session id}/frame 	Switch To Frame
-POST 	/session/{session id}/frame/parent 	Switch To Parent Frame
-POST 	/session/{session id}/element 	Find Element
-POST 	/session/{session id}/element/{element id}/element 	Find Element From Element
-POST 	/session/{session id}/elements 	Find Elements
-POST 	/session/{session id}/element/{element id}/elements 	Find Elements From Element
-GET 	/session/{session id}/element/active 	Get Active Element
-GET 	/session/{session id}/element/{element id}/selected 	Is Element Selected
-GET 	/session/{session id}/element/{element id}/attribute/{name} 	Get Element Attribute
-GET 	/session/{session id}/element/{element id}/property/{name} 	Get Element Property
-GET 	/session/{session id}/element/{element id}/css/{property name} 	Get Element CSS Value
-GET 	/session/{session id}/element/{element id}/text 	Get Element Text
-GET 	/session/{session id}/element/{element id}/name 	Get Element Tag Name
-GET 	/session/{session id}/element/{element id}/rect 	Get Element Rect
-GET 	/session/{session id}/element/{element id}/enabled 	Is Element Enabled
-GET 	/session/{session id}/source 	Get Page Source
-POST 	/session/{session id}/execute/sync 	Execute Script
-POST 	/session/{session id}/execute/async 	Execute Async Script
-GET 	/session/{session id}/cookie/{name} 	Get Cookie
-POST 	/session/{session id}/cookie 	Add Cookie
-DELETE 	/session/{session id}/cookie/{name} 	Delete Cookie
-DELETE 	/session/{session id)/cookie 	Delete All Cookies
-POST 	/session/{session id}/timeouts 	Set Timeout
-POST 	/session/{session id}/actions 	Perform Actions
-DELETE 	/session/{session id}/actions 	Releasing Actions
-POST 	/session/{session id}/element/{element id}/click 	Element Click
-POST 	/session/{session id}/element/{element id}/clear 	Element Clear
-POST 	/session/{session id}/element/{element id}/sendKeys 	Element Send Keys
-POST 	/session/{session id}/alert/dismiss 	Dismiss Alert
-POST 	/session/{session id}/alert/accept 	Accept Alert
-GET 	/session/{session id}/alert/text 	Get Alert Text
-POST 	/session/{session id}/alert/text 	Send Alert Text
-GET 	/session/{session id}/screenshot 	Take Screenshot
-GET 	/session/{session id}/element/{element id}/screenshot 	Take Element Screenshot
-*/
\ No newline at end of file
+// Endpoint is a W3C WebDriver command: the HTTP method and path template it is
+// sent with, and its name in the specification.
+type Endpoint struct {
+	Method string
+	Path   string
+	Name   string
+}
+
+// Endpoints lists every endpoint, in the order of the specification.
+var Endpoints = []Endpoint{
+	{"POST", NewSession, "New Session"},
+	{"DELETE", DeleteSession, "Delete Session"},
+	{"GET", Status, "Status"},
+	{"GET", GetTimeouts, "Get Timeouts"},
+	{"POST", SetTimeouts, "Set Timeouts"},
+	{"POST", Get, "Navigate To"},
+	{"GET", GetCurrentURL, "Get Current URL"},
+	{"POST", Back, "Back"},
+	{"POST", Forward, "Forward"},
+	{"POST", Refresh, "Refresh"},
+	{"GET", GetTitle, "Get Title"},
+	{"GET", GetWindowHandle, "Get Window Handle"},
+	{"DELETE", CloseWindow, "Close Window"},
+	{"POST", SwitchToWindow, "Switch To Window"},
+	{"GET", GetWindowHandles, "Get Window Handles"},
+	{"POST", NewWindow, "New Window"},
+	{"POST", SwitchToFrame, "Switch To Frame"},
+	{"POST", SwitchToParentFrame, "Switch To Parent Frame"},
+	{"GET", GetWindowRect, "Get Window Rect"},
+	{"POST", SetWindowRect, "Set Window Rect"},
+	{"POST", MaximizeWindow, "Maximize Window"},
+	{"POST", MinimizeWindow, "Minimize Window"},
+	{"POST", FullscreenWindow, "Fullscreen Window"},
+	{"GET", GetWindowSize, "Get Window Size"},
+	{"POST", SetWindowSize, "Set Window Size"},
+	{"GET", GetActiveElement, "Get Active Element"},
+	{"POST", FindElement, "Find Element"},
+	{"POST", FindElements, "Find Elements"},
+	{"POST", FindElementFromElement, "Find Element From Element"},
+	{"POST", FindElementsFromElement, "Find Elements From Element"},
+	{"GET", IsElementSelected, "Is Element Selected"},
+	{"GET", GetElementAttribute, "Get Element Attribute"},
+	{"GET", GetElementProperty, "Get Element Property"},
+	{"GET", GetElementCSSValue, "Get Element CSS Value"},
+	{"GET", GetElementText, "Get Element Text"},
+	{"GET", GetElementTagName, "Get Element Tag Name"},
+	{"GET", GetElementRect, "Get Element Rect"},
+	{"GET", IsElementEnabled, "Is Element Enabled"},
+	{"POST", ElementClick, "Element Click"},
+	{"POST", ElementClear, "Element Clear"},
+	{"POST", ElementSendKeys, "Element Send Keys"},
+	{"GET", GetPageSource, "Get Page Source"},
+	{"POST", ExecuteScript, "Execute Script"},
+	{"POST", ExecuteAsyncScript, "Execute Async Script"},
+	{"GET", GetAllCookies, "Get All Cookies"},
+	{"GET", GetNamedCookie, "Get Named Cookie"},
+	{"POST", AddCookie, "Add Cookie"},
+	{"DELETE", DeleteCookie, "Delete Cookie"},
+	{"DELETE", DeleteAllCookies, "Delete All Cookies"},
+	{"POST", PerformActions, "Perform Actions"},
+	{"DELETE", ReleaseActions, "Release Actions"},
+	{"POST", DismissAlert, "Dismiss Alert"},
+	{"POST", AcceptAlert, "Accept Alert"},
+	{"GET", GetAlertText, "Get Alert Text"},
+	{"POST", SendAlertText, "Send Alert Text"},
+	{"GET", TakeScreenshot, "Take Screenshot"},
+	{"GET", TakeElementScreenshot, "Take Element Screenshot"},
+}
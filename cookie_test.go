@@ -0,0 +1,137 @@
+package marionette_client
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func TestCookiesFake(t *testing.T) {
+	c, s := connect(t)
+	s.Handle("getCookies", func(cmd marionettetest.Command) (interface{}, error) {
+		return []interface{}{
+			map[string]interface{}{"name": "a", "value": "1", "domain": "example.com", "path": "/", "httpOnly": true, "secure": false, "sameSite": "Lax"},
+			map[string]interface{}{"name": "b", "value": "2", "domain": ".example.com", "path": "/app", "httpOnly": false, "secure": true, "expiry": 2000000000},
+		}, nil
+	})
+	s.HandleValue("addCookie", nil)
+	s.HandleValue("deleteCookie", nil)
+	s.HandleValue("deleteAllCookies", nil)
+
+	cookies, err := c.GetCookies()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Cookie{
+		{Domain: "example.com", HttpOnly: true, Name: "a", Path: "/", Value: "1", SameSite: SameSiteLax},
+		{Domain: ".example.com", Name: "b", Path: "/app", Value: "2", Expiry: 2000000000, Secure: true},
+	}
+	if !reflect.DeepEqual(cookies, want) {
+		t.Fatalf("expected %+v, got %+v", want, cookies)
+	}
+
+	if cookie, err := c.GetCookie("b"); err != nil || *cookie != want[1] {
+		t.Fatalf("got %+v, %v", cookie, err)
+	}
+
+	if _, err := c.GetCookie("c"); !errors.Is(err, ErrNoSuchCookie) {
+		t.Fatalf("expected ErrNoSuchCookie, got %v", err)
+	}
+
+	if err := c.AddCookie(Cookie{Name: "c", Value: "3", Secure: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DeleteCookie("a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DeleteAllCookies(); err != nil {
+		t.Fatal(err)
+	}
+
+	var add struct {
+		Cookie map[string]interface{}
+	}
+	s.Received("addCookie")[0].Decode(&add)
+	if len(add.Cookie) != 4 || add.Cookie["name"] != "c" || add.Cookie["secure"] != true || add.Cookie["httpOnly"] != false {
+		t.Fatalf("unexpected addCookie parameters %v", add.Cookie)
+	}
+
+	var del struct {
+		Name string
+	}
+	s.Received("deleteCookie")[0].Decode(&del)
+	if del.Name != "a" || len(s.Received("deleteAllCookies")) != 1 {
+		t.Fatalf("unexpected commands %v", s.Commands())
+	}
+}
+
+func TestCookiesTxt(t *testing.T) {
+	cookies := []Cookie{
+		{Domain: "example.com", HttpOnly: true, Name: "a", Path: "/", Value: "1"},
+		{Domain: ".example.com", Name: "b", Path: "/app", Value: "x=y", Expiry: 2000000000, Secure: true},
+	}
+
+	var b strings.Builder
+	if err := WriteCookiesTxt(&b, cookies); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# Netscape HTTP Cookie File\n" +
+		"#HttpOnly_example.com\tFALSE\t/\tFALSE\t0\ta\t1\n" +
+		".example.com\tTRUE\t/app\tTRUE\t2000000000\tb\tx=y\n"
+	if b.String() != want {
+		t.Fatalf("expected %q, got %q", want, b.String())
+	}
+
+	read, err := ReadCookiesTxt(strings.NewReader(b.String() + "\n# comment\r\n"))
+	if err != nil || !reflect.DeepEqual(read, cookies) {
+		t.Fatalf("expected %+v, got %+v, %v", cookies, read, err)
+	}
+
+	if _, err := ReadCookiesTxt(strings.NewReader("example.com\tFALSE\t/\n")); err == nil {
+		t.Fatal("expected an error for a truncated line")
+	}
+}
+
+func TestNewCookieJar(t *testing.T) {
+	jar, err := NewCookieJar([]Cookie{
+		{Domain: "example.com", Name: "host", Path: "/", Value: "1"},
+		{Domain: ".example.com", Name: "domain", Path: "/", Value: "2"},
+		{Domain: "example.com", Name: "secure", Path: "/", Value: "3", Secure: true},
+		{Domain: "example.com", Name: "app", Path: "/app", Value: "4"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		url     string
+		cookies string
+	}{
+		{"http://example.com/", "host=1 domain=2"},
+		{"https://example.com/app/", "app=4 host=1 domain=2 secure=3"},
+		{"http://www.example.com/", "domain=2"},
+	} {
+		u, _ := url.Parse(tc.url)
+		if got := cookieString(jar.Cookies(u)); got != tc.cookies {
+			t.Errorf("%v: expected %q, got %q", tc.url, tc.cookies, got)
+		}
+	}
+}
+
+func cookieString(cookies []*http.Cookie) string {
+	var s []string
+	for _, c := range cookies {
+		s = append(s, c.Name+"="+c.Value)
+	}
+
+	return strings.Join(s, " ")
+}
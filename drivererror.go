@@ -1,15 +1,103 @@
 package marionette_client
 
+import (
+	"errors"
+)
+
+// Errors for the WebDriver error codes. A *DriverError unwraps to the one
+// matching its ErrorType, so callers can test for them with errors.Is:
+//
+//	if errors.Is(err, ErrNoSuchElement) {
+//		// ...
+//	}
+var (
+	ErrDetachedShadowRoot      = errors.New("detached shadow root")
+	ErrElementClickIntercepted = errors.New("element click intercepted")
+	ErrElementNotInteractable  = errors.New("element not interactable")
+	ErrInsecureCertificate     = errors.New("insecure certificate")
+	ErrInvalidArgument         = errors.New("invalid argument")
+	ErrInvalidCookieDomain     = errors.New("invalid cookie domain")
+	ErrInvalidElementState     = errors.New("invalid element state")
+	ErrInvalidSelector         = errors.New("invalid selector")
+	ErrInvalidSessionID        = errors.New("invalid session id")
+	ErrJavaScript              = errors.New("javascript error")
+	ErrMoveTargetOutOfBounds   = errors.New("move target out of bounds")
+	ErrNoSuchAlert             = errors.New("no such alert")
+	ErrNoSuchCookie            = errors.New("no such cookie")
+	ErrNoSuchElement           = errors.New("no such element")
+	ErrNoSuchFrame             = errors.New("no such frame")
+	ErrNoSuchShadowRoot        = errors.New("no such shadow root")
+	ErrNoSuchWindow            = errors.New("no such window")
+	ErrScriptTimeout           = errors.New("script timeout")
+	ErrSessionNotCreated       = errors.New("session not created")
+	ErrStaleElementReference   = errors.New("stale element reference")
+	ErrTimeout                 = errors.New("timeout")
+	ErrUnableToCaptureScreen   = errors.New("unable to capture screen")
+	ErrUnableToSetCookie       = errors.New("unable to set cookie")
+	ErrUnexpectedAlertOpen     = errors.New("unexpected alert open")
+	ErrUnknownCommand          = errors.New("unknown command")
+	ErrUnknownError            = errors.New("unknown error")
+	ErrUnknownMethod           = errors.New("unknown method")
+	ErrUnsupportedOperation    = errors.New("unsupported operation")
+)
+
+var errorCodes = map[string]error{}
+
+func init() {
+	for _, err := range []error{
+		ErrDetachedShadowRoot, ErrElementClickIntercepted, ErrElementNotInteractable,
+		ErrInsecureCertificate, ErrInvalidArgument, ErrInvalidCookieDomain,
+		ErrInvalidElementState, ErrInvalidSelector, ErrInvalidSessionID, ErrJavaScript,
+		ErrMoveTargetOutOfBounds, ErrNoSuchAlert, ErrNoSuchCookie, ErrNoSuchElement,
+		ErrNoSuchFrame, ErrNoSuchShadowRoot, ErrNoSuchWindow, ErrScriptTimeout,
+		ErrSessionNotCreated, ErrStaleElementReference, ErrTimeout,
+		ErrUnableToCaptureScreen, ErrUnableToSetCookie, ErrUnexpectedAlertOpen,
+		ErrUnknownCommand, ErrUnknownError, ErrUnknownMethod, ErrUnsupportedOperation,
+	} {
+		errorCodes[err.Error()] = err
+	}
+
+	// codes sent by older Marionette versions
+	errorCodes["element not visible"] = ErrElementNotInteractable
+	errorCodes["element not selectable"] = ErrElementNotInteractable
+	errorCodes["invalid xpath selector"] = ErrInvalidSelector
+	errorCodes["invalid xpath selector return typer"] = ErrInvalidSelector
+}
+
+// DriverError is an error returned by Marionette. ErrorType holds the WebDriver
+// error code ("no such element", "stale element reference", ...), Stacktrace
+// the remote JavaScript stack, if any, and Data the additional error data,
+// such as the text of the alert for "unexpected alert open".
 type DriverError struct {
 	ErrorType  string `json:"Error"`
 	Message    string
 	Stacktrace *string
+	Data       map[string]interface{}
 }
 
 func (e DriverError) Error() string {
+	if e.Message == "" {
+		return e.ErrorType
+	}
+
 	return e.Message
 }
 
 func (e DriverError) String() string {
 	return e.Error()
 }
+
+// Unwrap returns the Err* error of the WebDriver error code, nil when the code
+// is unknown.
+func (e DriverError) Unwrap() error {
+	return errorCodes[e.ErrorType]
+}
+
+// retryable reports whether err is a driver error worth polling again for,
+// because the element, alert or frame may still show up or be found again.
+func retryable(err error) bool {
+	return errors.Is(err, ErrNoSuchElement) ||
+		errors.Is(err, ErrStaleElementReference) ||
+		errors.Is(err, ErrNoSuchAlert) ||
+		errors.Is(err, ErrNoSuchFrame)
+}
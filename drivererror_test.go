@@ -0,0 +1,82 @@
+package marionette_client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDecodeDriverError(t *testing.T) {
+	buf := []byte(`[1,7,{"error":"stale element reference","message":"The element is no longer attached","stacktrace":"findElement@chrome://marionette"},null]`)
+	r := &Response{}
+	err := ProtoV3DecoderEncoder{}.Decode(buf, r)
+
+	if !errors.Is(err, ErrStaleElementReference) || errors.Is(err, ErrNoSuchElement) {
+		t.Fatalf("unexpected error %#v", err)
+	}
+
+	var de *DriverError
+	if !errors.As(err, &de) {
+		t.Fatalf("expected a *DriverError, got %#v", err)
+	}
+
+	if de.Stacktrace == nil || *de.Stacktrace != "findElement@chrome://marionette" {
+		t.Fatalf("stacktrace was not preserved: %#v", de)
+	}
+
+	if r.MessageID != 7 {
+		t.Fatalf("expected message id 7, got %v", r.MessageID)
+	}
+}
+
+func TestDriverErrorCodes(t *testing.T) {
+	for code, want := range map[string]error{
+		"no such element":       ErrNoSuchElement,
+		"javascript error":      ErrJavaScript,
+		"unexpected alert open": ErrUnexpectedAlertOpen,
+		"invalid session id":    ErrInvalidSessionID,
+		"element not visible":   ErrElementNotInteractable,
+	} {
+		err := error(&DriverError{ErrorType: code})
+		if !errors.Is(err, want) {
+			t.Errorf("%q doesn't match %v", code, want)
+		}
+	}
+
+	if errors.Unwrap(&DriverError{ErrorType: "something new"}) != nil {
+		t.Error("unknown codes should not unwrap")
+	}
+}
+
+type errFinder struct {
+	calls int
+	err   error
+}
+
+func (f *errFinder) FindElement(by By, value string) (*WebElement, error) {
+	f.calls++
+	return nil, f.err
+}
+
+func (f *errFinder) FindElements(by By, value string) ([]*WebElement, error) {
+	return nil, f.err
+}
+
+func TestUntilStopsOnFatalDriverError(t *testing.T) {
+	f := &errFinder{err: &DriverError{ErrorType: "no such window", Message: "window closed"}}
+	ok, _, err := Wait(f).For(time.Minute).Until(ElementIsPresent(By(ID), "id"))
+	if ok || !errors.Is(err, ErrNoSuchWindow) || f.calls != 1 {
+		t.Fatalf("expected the wait to stop at once, got %v, %#v after %v calls", ok, err, f.calls)
+	}
+
+	f = &errFinder{err: &DriverError{ErrorType: "no such element"}}
+	ok, _, err = Wait(f).For(1100 * time.Millisecond).Until(ElementIsPresent(By(ID), "id"))
+	if ok || errors.Is(err, ErrNoSuchElement) || f.calls < 2 {
+		t.Fatalf("expected the wait to poll until the timeout, got %v, %#v after %v calls", ok, err, f.calls)
+	}
+
+	var te *TimeoutError
+	if !errors.As(err, &te) || !errors.Is(te.LastErr, ErrNoSuchElement) {
+		t.Fatalf("expected a *TimeoutError carrying the last error, got %#v", err)
+	}
+}
@@ -1,6 +1,33 @@
 package marionette_client
 
-func ElementIsPresent(by By, value string) func(f Finder) (bool, *WebElement, error) {
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Condition is polled by a Waiter until it holds. It reports whether it holds,
+// and the element it is about, if any. Errors it returns end the wait, unless
+// they are driver errors of an element, alert or frame that may still show up.
+//
+// Conditions about the page, like TitleContains, need the Finder to be a
+// *Client or a *WebElement.
+type Condition func(f Finder) (bool, *WebElement, error)
+
+// Func returns c as a function for WaitFor, checking c against f bound to the
+// context of the wait:
+//
+//	e, err := WaitFor(ctx, Wait(client), ElementIsPresent(By(ID), "q").Func(client))
+func (c Condition) Func(f Finder) func(ctx context.Context) (*WebElement, bool, error) {
+	return func(ctx context.Context) (*WebElement, bool, error) {
+		ok, e, err := c(bindFinder(ctx, f))
+		return e, ok, err
+	}
+}
+
+func ElementIsPresent(by By, value string) Condition {
 	return func(f Finder) (bool, *WebElement, error) {
 		result := true
 		v, e := f.FindElement(by, value)
@@ -12,7 +39,7 @@ func ElementIsPresent(by By, value string) func(f Finder) (bool, *WebElement, er
 	}
 }
 
-func ElementIsNotPresent(by By, value string) func(f Finder) (bool, *WebElement, error) {
+func ElementIsNotPresent(by By, value string) Condition {
 	return func(f Finder) (bool, *WebElement, error) {
 		result := false
 		v, e := f.FindElement(by, value)
@@ -23,3 +50,280 @@ func ElementIsNotPresent(by By, value string) func(f Finder) (bool, *WebElement,
 		return result, v, e
 	}
 }
+
+// elementCondition returns a condition holding when the element located by by
+// and value is found and satisfies check.
+func elementCondition(by By, value string, check func(e *WebElement) (bool, error)) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		e, err := f.FindElement(by, value)
+		if err != nil {
+			return false, nil, err
+		}
+
+		ok, err := check(e)
+		if err != nil || !ok {
+			return false, nil, err
+		}
+
+		return true, e, nil
+	}
+}
+
+// ElementIsVisible holds when the element is present and displayed.
+func ElementIsVisible(by By, value string) Condition {
+	return elementCondition(by, value, func(e *WebElement) (bool, error) {
+		return e.Displayed()
+	})
+}
+
+// ElementIsInvisible holds when the element is absent, stale or not
+// displayed.
+func ElementIsInvisible(by By, value string) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		e, err := f.FindElement(by, value)
+		if errors.Is(err, ErrNoSuchElement) {
+			return true, nil, nil
+		}
+
+		if err != nil {
+			return false, nil, err
+		}
+
+		displayed, err := e.Displayed()
+		if errors.Is(err, ErrStaleElementReference) {
+			return true, nil, nil
+		}
+
+		return err == nil && !displayed, nil, err
+	}
+}
+
+// ElementIsClickable holds when the element is displayed and enabled.
+func ElementIsClickable(by By, value string) Condition {
+	return elementCondition(by, value, func(e *WebElement) (bool, error) {
+		displayed, err := e.Displayed()
+		if err != nil || !displayed {
+			return false, err
+		}
+
+		return e.Enabled()
+	})
+}
+
+// ElementTextContains holds when the text of the element contains text.
+func ElementTextContains(by By, value string, text string) Condition {
+	return elementCondition(by, value, func(e *WebElement) (bool, error) {
+		t, err := e.Text()
+		return strings.Contains(t, text), err
+	})
+}
+
+// ElementTextMatches holds when the text of the element matches re.
+func ElementTextMatches(by By, value string, re *regexp.Regexp) Condition {
+	return elementCondition(by, value, func(e *WebElement) (bool, error) {
+		t, err := e.Text()
+		return re.MatchString(t), err
+	})
+}
+
+// ElementAttributeIs holds when the attribute name of the element is want.
+func ElementAttributeIs(by By, value string, name string, want string) Condition {
+	return elementCondition(by, value, func(e *WebElement) (bool, error) {
+		v, err := e.Attribute(name)
+		return v == want, err
+	})
+}
+
+// ElementCountIs holds when n elements are located by by and value.
+func ElementCountIs(by By, value string, n int) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		elements, err := f.FindElements(by, value)
+		return err == nil && len(elements) == n, nil, err
+	}
+}
+
+// ElementCountAtLeast holds when at least n elements are located by by and
+// value. The first one is returned.
+func ElementCountAtLeast(by By, value string, n int) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		elements, err := f.FindElements(by, value)
+		if err != nil || len(elements) < n {
+			return false, nil, err
+		}
+
+		var first *WebElement
+		if len(elements) > 0 {
+			first = elements[0]
+		}
+
+		return true, first, nil
+	}
+}
+
+// ElementIsStale holds when e is no longer attached to the document, e.g.
+// once the page it was found on is unloaded. e isn't located again, even when
+// stale element retry is on.
+func ElementIsStale(e *WebElement) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		_, err := getElementTagName(e.c, e.Id())
+		if errors.Is(err, ErrStaleElementReference) || errors.Is(err, ErrNoSuchElement) {
+			return true, nil, nil
+		}
+
+		return false, nil, err
+	}
+}
+
+// finderClient returns the client f sends its commands through.
+func finderClient(f Finder) (*Client, error) {
+	switch v := f.(type) {
+	case *Client:
+		return v, nil
+	case *WebElement:
+		return v.c, nil
+	}
+
+	return nil, fmt.Errorf("condition needs a *Client or *WebElement, got %T", f)
+}
+
+// pageCondition returns a condition holding when check holds for the client
+// of the Finder.
+func pageCondition(check func(c *Client) (bool, error)) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		c, err := finderClient(f)
+		if err != nil {
+			return false, nil, err
+		}
+
+		ok, err := check(c)
+		return err == nil && ok, nil, err
+	}
+}
+
+// TitleIs holds when the title of the document is title.
+func TitleIs(title string) Condition {
+	return pageCondition(func(c *Client) (bool, error) {
+		t, err := c.Title()
+		return t == title, err
+	})
+}
+
+// TitleContains holds when the title of the document contains s.
+func TitleContains(s string) Condition {
+	return pageCondition(func(c *Client) (bool, error) {
+		t, err := c.Title()
+		return strings.Contains(t, s), err
+	})
+}
+
+// URLContains holds when the URL of the document contains s.
+func URLContains(s string) Condition {
+	return pageCondition(func(c *Client) (bool, error) {
+		u, err := c.Url()
+		return strings.Contains(u, s), err
+	})
+}
+
+// URLMatches holds when the URL of the document matches re.
+func URLMatches(re *regexp.Regexp) Condition {
+	return pageCondition(func(c *Client) (bool, error) {
+		u, err := c.Url()
+		return re.MatchString(u), err
+	})
+}
+
+// AlertIsPresent holds when a user prompt is open.
+func AlertIsPresent() Condition {
+	return pageCondition(func(c *Client) (bool, error) {
+		_, err := c.TextFromDialog()
+		return err == nil, err
+	})
+}
+
+// WindowCountIs holds when n windows or tabs are open.
+func WindowCountIs(n int) Condition {
+	return pageCondition(func(c *Client) (bool, error) {
+		handles, err := c.WindowHandles()
+		return len(handles) == n, err
+	})
+}
+
+// FrameIsAvailable holds when the frame element located by by and value is
+// present, and switches to it.
+func FrameIsAvailable(by By, value string) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		c, err := finderClient(f)
+		if err != nil {
+			return false, nil, err
+		}
+
+		e, err := f.FindElement(by, value)
+		if err != nil {
+			return false, nil, err
+		}
+
+		if err := c.SwitchToFrameElement(e); err != nil {
+			return false, nil, err
+		}
+
+		return true, e, nil
+	}
+}
+
+// pending reports whether err only means that a condition doesn't hold yet.
+func pending(err error) bool {
+	return err == nil || retryable(err)
+}
+
+// And holds when all the conditions hold, evaluated in order. It returns the
+// element of the last one.
+func And(conditions ...Condition) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		var e *WebElement
+		for _, condition := range conditions {
+			ok, v, err := condition(f)
+			if !ok {
+				return false, nil, err
+			}
+
+			e = v
+		}
+
+		return true, e, nil
+	}
+}
+
+// Or holds when one of the conditions holds, evaluated in order. It returns
+// the element of the first one holding.
+func Or(conditions ...Condition) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		var fatal error
+		for _, condition := range conditions {
+			ok, v, err := condition(f)
+			if ok {
+				return true, v, nil
+			}
+
+			if !pending(err) && fatal == nil {
+				fatal = err
+			}
+		}
+
+		return false, nil, fatal
+	}
+}
+
+// Not holds when condition doesn't, including when it fails with the driver
+// error of an element, alert or frame not found.
+//
+//	Not(ElementIsPresent(By(ID), "spinner"))
+func Not(condition Condition) Condition {
+	return func(f Finder) (bool, *WebElement, error) {
+		ok, _, err := condition(f)
+		if !pending(err) {
+			return false, nil, err
+		}
+
+		return !ok, nil, nil
+	}
+}
@@ -0,0 +1,104 @@
+package marionette_client
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func TestElementConditionsFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("findElement", marionettetest.Element("e1"))
+	s.HandleValue("isElementDisplayed", true)
+	s.HandleValue("isElementEnabled", false)
+	s.HandleValue("getElementText", "3 results")
+	s.HandleValue("getElementAttribute", "done")
+	s.Handle("findElements", func(cmd marionettetest.Command) (interface{}, error) {
+		return []interface{}{marionettetest.Element("e1"), marionettetest.Element("e2")}, nil
+	})
+
+	for name, tc := range map[string]struct {
+		condition Condition
+		ok        bool
+	}{
+		"visible":           {ElementIsVisible(By(ID), "x"), true},
+		"invisible":         {ElementIsInvisible(By(ID), "x"), false},
+		"clickable":         {ElementIsClickable(By(ID), "x"), false},
+		"text contains":     {ElementTextContains(By(ID), "x", "results"), true},
+		"text matches":      {ElementTextMatches(By(ID), "x", regexp.MustCompile(`^\d+ results$`)), true},
+		"text mismatch":     {ElementTextMatches(By(ID), "x", regexp.MustCompile(`^no`)), false},
+		"attribute":         {ElementAttributeIs(By(ID), "x", "class", "done"), true},
+		"count":             {ElementCountIs(By(TAG_NAME), "li", 2), true},
+		"count at least":    {ElementCountAtLeast(By(TAG_NAME), "li", 3), false},
+		"and":               {And(ElementIsVisible(By(ID), "x"), ElementTextContains(By(ID), "x", "3")), true},
+		"and failing":       {And(ElementIsVisible(By(ID), "x"), ElementIsClickable(By(ID), "x")), false},
+		"or":                {Or(ElementIsClickable(By(ID), "x"), ElementIsVisible(By(ID), "x")), true},
+		"not":               {Not(ElementIsClickable(By(ID), "x")), true},
+		"not of not found":  {Not(ElementIsPresent(By(ID), "x")), false},
+		"not of not absent": {Not(ElementIsNotPresent(By(ID), "x")), true},
+	} {
+		ok, _, err := tc.condition(c)
+		if err != nil || ok != tc.ok {
+			t.Errorf("%v: expected %v, got %v, %v", name, tc.ok, ok, err)
+		}
+	}
+
+	s.HandleError("findElement", "no such element", "")
+	if ok, _, err := ElementIsInvisible(By(ID), "x")(c); !ok || err != nil {
+		t.Fatalf("expected an absent element to be invisible, got %v, %v", ok, err)
+	}
+
+	if ok, _, err := Not(ElementIsPresent(By(ID), "x"))(c); !ok || err != nil {
+		t.Fatalf("expected Not to hold for an absent element, got %v, %v", ok, err)
+	}
+
+	s.HandleError("findElement", "no such window", "")
+	if ok, _, err := Or(ElementIsVisible(By(ID), "x"), ElementIsPresent(By(ID), "y"))(c); ok || !errors.Is(err, ErrNoSuchWindow) {
+		t.Fatalf("expected Or to fail with the fatal error, got %v, %v", ok, err)
+	}
+}
+
+func TestPageConditionsFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("getTitle", "Example Domain")
+	s.HandleValue("getCurrentUrl", "https://example.com/checkout?step=2")
+	s.Handle("getWindowHandles", func(cmd marionettetest.Command) (interface{}, error) {
+		return []string{"1", "2"}, nil
+	})
+	s.HandleError("getTextFromDialog", "no such alert", "")
+	s.HandleError("getElementTagName", "stale element reference", "")
+	s.HandleValue("findElement", marionettetest.Element("f1"))
+	s.HandleValue("switchToFrame", nil)
+
+	for name, tc := range map[string]struct {
+		condition Condition
+		ok        bool
+	}{
+		"title is":       {TitleIs("Example Domain"), true},
+		"title contains": {TitleContains("Other"), false},
+		"url contains":   {URLContains("/checkout"), true},
+		"url matches":    {URLMatches(regexp.MustCompile(`step=\d`)), true},
+		"windows":        {WindowCountIs(2), true},
+		"stale":          {ElementIsStale(c.ElementFromID("e1")), true},
+		"frame":          {FrameIsAvailable(By(ID), "payment"), true},
+	} {
+		ok, _, err := tc.condition(c)
+		if err != nil || ok != tc.ok {
+			t.Errorf("%v: expected %v, got %v, %v", name, tc.ok, ok, err)
+		}
+	}
+
+	if ok, _, err := AlertIsPresent()(c); ok || !errors.Is(err, ErrNoSuchAlert) {
+		t.Fatalf("expected no alert, got %v, %v", ok, err)
+	}
+
+	if len(s.Received("switchToFrame")) != 1 {
+		t.Fatal("expected FrameIsAvailable to switch to the frame")
+	}
+
+	if _, _, err := TitleIs("x")(notFoundFinder{}); err == nil {
+		t.Fatal("expected an error for a Finder without a client")
+	}
+}
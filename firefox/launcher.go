@@ -0,0 +1,321 @@
+// Package firefox starts Firefox with Marionette enabled and manages its
+// lifecycle.
+//
+//	p, err := firefox.Launch(ctx, &firefox.Options{Headless: true})
+//	if err != nil {
+//		// handle your errors
+//	}
+//	defer p.Close()
+//
+//	p.Client.NewSession("", nil)
+package firefox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	marionette "github.com/njasm/marionette_client"
+)
+
+const (
+	defaultStartTimeout = 30 * time.Second
+	defaultQuitTimeout  = 10 * time.Second
+)
+
+// Options configures how Firefox is launched. The zero value launches the
+// Firefox binary found by FindBinary, with a window and a random Marionette port.
+type Options struct {
+	Binary   string    // path of the Firefox executable, found by FindBinary when empty
+	Args     []string  // extra command line arguments
+	Env      []string  // extra environment variables, as key=value
+	Headless bool      // run without a window
+	Port     int       // Marionette port, a free one when 0
+	Profile  *Profile  // profile written to the temporary directory, NewProfile() when nil
+	Stdout   io.Writer // also receives the standard output of Firefox, if set
+	Stderr   io.Writer // also receives the standard error of Firefox, if set
+
+	// StartTimeout bounds the wait for the Marionette handshake, 30 seconds
+	// when 0. QuitTimeout bounds the wait for Firefox to exit after
+	// quitApplication before it is killed, 10 seconds when 0.
+	StartTimeout time.Duration
+	QuitTimeout  time.Duration
+}
+
+// Process is a running Firefox, and a Client connected to its Marionette
+// server.
+type Process struct {
+	Client     *marionette.Client
+	Port       int
+	ProfileDir string
+
+	cmd         *exec.Cmd
+	quitTimeout time.Duration
+	exited      chan struct{}
+	waitErr     error
+	output      *buffer
+	closeOnce   sync.Once
+	closeErr    error
+}
+
+// Launch starts Firefox with a new temporary profile and waits until its
+// Marionette server accepts the handshake. Cancelling ctx aborts the wait and
+// kills Firefox. The cookies and permissions of Options.Profile are not set,
+// call Profile.Apply after NewSession.
+func Launch(ctx context.Context, opts *Options) (*Process, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	binary := opts.Binary
+	if binary == "" {
+		var err error
+		binary, err = FindBinary()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	port := opts.Port
+	if port == 0 {
+		var err error
+		port, err = freePort()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "marionette-profile-")
+	if err != nil {
+		return nil, err
+	}
+
+	profile := opts.Profile
+	if profile == nil {
+		profile = NewProfile()
+	}
+
+	if err := profile.Write(dir); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	if err := writePrefs(dir, port); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	args := []string{"-marionette", "-no-remote", "-new-instance", "-profile", dir}
+	if opts.Headless {
+		args = append(args, "-headless")
+	}
+
+	p := &Process{
+		Port:        port,
+		ProfileDir:  dir,
+		cmd:         exec.Command(binary, append(args, opts.Args...)...),
+		quitTimeout: opts.QuitTimeout,
+		exited:      make(chan struct{}),
+		output:      &buffer{},
+	}
+
+	if p.quitTimeout == 0 {
+		p.quitTimeout = defaultQuitTimeout
+	}
+
+	p.cmd.Env = append(os.Environ(), opts.Env...)
+	if opts.Headless {
+		p.cmd.Env = append(p.cmd.Env, "MOZ_HEADLESS=1")
+	}
+
+	p.cmd.Stdout = tee(p.output, opts.Stdout)
+	p.cmd.Stderr = tee(p.output, opts.Stderr)
+	if err := p.cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	go func() {
+		p.waitErr = p.cmd.Wait()
+		close(p.exited)
+	}()
+
+	timeout := opts.StartTimeout
+	if timeout == 0 {
+		timeout = defaultStartTimeout
+	}
+
+	if err := p.connect(ctx, timeout); err != nil {
+		p.kill()
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// connect retries the Marionette handshake until it succeeds, Firefox exits or
+// the timeout expires.
+func (p *Process) connect(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		c := marionette.NewClient()
+		err := c.BindContext(ctx).Connect("127.0.0.1", p.Port)
+		if err == nil {
+			p.Client = c
+			return nil
+		}
+
+		select {
+		case <-p.exited:
+			return fmt.Errorf("Firefox exited before Marionette was ready: %v\n%s", p.waitErr, p.output.Bytes())
+		case <-ctx.Done():
+			return fmt.Errorf("Marionette was not ready on port %v: %v\n%s", p.Port, ctx.Err(), p.output.Bytes())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Output returns everything Firefox wrote so far to its standard output and
+// standard error.
+func (p *Process) Output() []byte {
+	return p.output.Bytes()
+}
+
+// Exited is closed once the Firefox process has exited.
+func (p *Process) Exited() <-chan struct{} {
+	return p.exited
+}
+
+// Close asks Firefox to quit through Marionette, kills it when it doesn't exit
+// within the quit timeout, and removes the temporary profile.
+func (p *Process) Close() error {
+	p.closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), p.quitTimeout)
+		defer cancel()
+
+		if p.Client != nil {
+			p.Client.BindContext(ctx).QuitApplication()
+			p.Client.Close()
+		}
+
+		select {
+		case <-p.exited:
+		case <-ctx.Done():
+			p.kill()
+		}
+
+		p.closeErr = os.RemoveAll(p.ProfileDir)
+	})
+
+	return p.closeErr
+}
+
+func (p *Process) kill() {
+	p.cmd.Process.Kill()
+	<-p.exited
+	if p.Client == nil {
+		os.RemoveAll(p.ProfileDir)
+	}
+}
+
+// FindBinary returns the path of the Firefox executable: the value of the
+// FIREFOX_BIN environment variable if set, else the first firefox in PATH, else
+// the default install location of the platform.
+func FindBinary() (string, error) {
+	if bin := os.Getenv("FIREFOX_BIN"); bin != "" {
+		return bin, nil
+	}
+
+	for _, name := range []string{"firefox", "firefox-bin"} {
+		if bin, err := exec.LookPath(name); err == nil {
+			return bin, nil
+		}
+	}
+
+	var candidates []string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = []string{"/Applications/Firefox.app/Contents/MacOS/firefox"}
+	case "windows":
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+			if dir := os.Getenv(env); dir != "" {
+				candidates = append(candidates, filepath.Join(dir, "Mozilla Firefox", "firefox.exe"))
+			}
+		}
+	default:
+		candidates = []string{"/usr/lib/firefox/firefox", "/usr/lib64/firefox/firefox", "/opt/firefox/firefox", "/snap/bin/firefox"}
+	}
+
+	for _, bin := range candidates {
+		if _, err := os.Stat(bin); err == nil {
+			return bin, nil
+		}
+	}
+
+	return "", errors.New("Firefox not found. set FIREFOX_BIN or Options.Binary.")
+}
+
+// writePrefs appends the preferences enabling Marionette on port to the
+// user.js of the profile.
+func writePrefs(dir string, port int) error {
+	f, err := os.OpenFile(filepath.Join(dir, "user.js"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(f, "user_pref(\"marionette.enabled\", true);\nuser_pref(\"marionette.port\", %v);\n", port)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+func tee(b *buffer, w io.Writer) io.Writer {
+	if w == nil {
+		return b
+	}
+
+	return io.MultiWriter(b, w)
+}
+
+// buffer is a bytes.Buffer safe for concurrent use.
+type buffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.b.Write(p)
+}
+
+func (b *buffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]byte(nil), b.b.Bytes()...)
+}
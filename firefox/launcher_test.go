@@ -0,0 +1,166 @@
+package firefox
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+// When FAKE_FIREFOX is set the test binary acts as Firefox: it serves the fake
+// Marionette server on the port of the profile given with -profile.
+// FAKE_FIREFOX=ignore-quit makes it answer quitApplication without exiting,
+// FAKE_FIREFOX=hang makes it never listen.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("FAKE_FIREFOX"); mode != "" {
+		fakeFirefox(mode)
+		return
+	}
+
+	os.Exit(m.Run())
+}
+
+func fakeFirefox(mode string) {
+	profile := ""
+	for i, arg := range os.Args {
+		if arg == "-profile" && i+1 < len(os.Args) {
+			profile = os.Args[i+1]
+		}
+	}
+
+	prefs, err := os.ReadFile(filepath.Join(profile, "user.js"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	port := regexp.MustCompile(`"marionette.port", (\d+)`).FindSubmatch(prefs)
+	if port == nil {
+		fmt.Fprintln(os.Stderr, "marionette.port is not set")
+		os.Exit(2)
+	}
+
+	// simulate a slow start-up.
+	time.Sleep(200 * time.Millisecond)
+	if mode == "hang" {
+		select {}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:"+string(port[1]))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	s := marionettetest.NewUnstartedServer()
+	s.Handle("quitApplication", func(marionettetest.Command) (interface{}, error) {
+		if mode != "ignore-quit" {
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				os.Exit(0)
+			}()
+		}
+
+		return map[string]string{"cause": "shutdown"}, nil
+	})
+
+	fmt.Println("fake firefox listening on", l.Addr(), strings.Join(os.Args[1:], " "))
+	s.Serve(l)
+	select {}
+}
+
+func launch(t *testing.T, mode string, opts *Options) *Process {
+	t.Helper()
+	opts.Binary = os.Args[0]
+	opts.Env = append(opts.Env, "FAKE_FIREFOX="+mode)
+
+	p, err := Launch(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestLaunch(t *testing.T) {
+	var stdout bytes.Buffer
+	p := launch(t, "quit", &Options{Headless: true, Stdout: &stdout})
+
+	if _, err := p.Client.NewSession("", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(p.ProfileDir, "user.js")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-p.Exited():
+	default:
+		t.Fatal("Firefox is still running after Close")
+	}
+
+	if _, err := os.Stat(p.ProfileDir); !os.IsNotExist(err) {
+		t.Fatalf("profile %v was not removed: %v", p.ProfileDir, err)
+	}
+
+	out := string(p.Output())
+	for _, arg := range []string{"-marionette", "-headless", "-profile " + p.ProfileDir} {
+		if !strings.Contains(out, arg) {
+			t.Errorf("expected %q in the arguments, got %q", arg, out)
+		}
+	}
+
+	if stdout.String() != out {
+		t.Fatalf("stdout was not forwarded, got %q", stdout.String())
+	}
+}
+
+func TestCloseKillsFirefox(t *testing.T) {
+	p := launch(t, "ignore-quit", &Options{QuitTimeout: 200 * time.Millisecond})
+
+	start := time.Now()
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-p.Exited():
+	default:
+		t.Fatal("Firefox is still running after Close")
+	}
+
+	if d := time.Since(start); d < 200*time.Millisecond {
+		t.Fatalf("Firefox was killed before the quit timeout, after %v", d)
+	}
+
+	if _, err := os.Stat(p.ProfileDir); !os.IsNotExist(err) {
+		t.Fatalf("profile %v was not removed: %v", p.ProfileDir, err)
+	}
+}
+
+func TestLaunchTimeout(t *testing.T) {
+	_, err := Launch(context.Background(), &Options{Binary: os.Args[0], Env: []string{"FAKE_FIREFOX=hang"}, StartTimeout: 500 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Fatalf("expected a start-up timeout, got %v", err)
+	}
+}
+
+func TestLaunchExited(t *testing.T) {
+	_, err := Launch(context.Background(), &Options{Binary: os.Args[0], Env: []string{"FAKE_FIREFOX=quit"}, Args: []string{"-profile", "/nonexistent"}})
+	if err == nil || !strings.Contains(err.Error(), "exited") {
+		t.Fatalf("expected Firefox to exit, got %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+package firefox
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	marionette "github.com/njasm/marionette_client"
+)
+
+// Profile describes a Firefox profile: the preferences of its user.js, its
+// extensions, certificate overrides, cookies and permissions. Write creates the
+// profile directory, Encode serializes it for the moz:firefoxOptions capability.
+//
+// Firefox keeps cookies and permissions in SQLite databases, so they are not
+// part of the profile directory; Apply adds them once a session is started.
+type Profile struct {
+	prefs         map[string]string // name -> JavaScript literal
+	extensions    map[string]string // id -> path of the .xpi
+	certOverrides []CertOverride
+	cookies       []marionette.Cookie
+	permissions   []Permission
+}
+
+// CertOverride accepts the certificate with the given SHA-256 fingerprint,
+// written as colon separated hexadecimal bytes, for host:port.
+type CertOverride struct {
+	Host        string
+	Port        int
+	Fingerprint string
+}
+
+// Permission allows or denies a permission, like "geo" or "desktop-notification",
+// to an origin like "https://example.com".
+type Permission struct {
+	Origin string
+	Type   string
+	Allow  bool
+}
+
+// NewProfile returns a Profile whose preferences keep Firefox from checking
+// the default browser, showing first-run pages and updating itself.
+func NewProfile() *Profile {
+	p := &Profile{
+		prefs:      make(map[string]string),
+		extensions: make(map[string]string),
+	}
+
+	p.SetBoolPref("browser.shell.checkDefaultBrowser", false)
+	p.SetStringPref("browser.startup.homepage_override.mstone", "ignore")
+	p.SetBoolPref("browser.tabs.warnOnClose", false)
+	p.SetBoolPref("datareporting.policy.dataSubmissionEnabled", false)
+	p.SetBoolPref("app.update.disabledForTesting", true)
+
+	return p
+}
+
+// SetBoolPref sets a boolean preference.
+func (p *Profile) SetBoolPref(name string, value bool) {
+	p.prefs[name] = strconv.FormatBool(value)
+}
+
+// SetIntPref sets an integer preference.
+func (p *Profile) SetIntPref(name string, value int) {
+	p.prefs[name] = strconv.Itoa(value)
+}
+
+// SetStringPref sets a string preference.
+func (p *Profile) SetStringPref(name string, value string) {
+	b, _ := json.Marshal(value)
+	p.prefs[name] = string(b)
+}
+
+// ClearPref removes a preference set on p, Firefox will use its default value.
+func (p *Profile) ClearPref(name string) {
+	delete(p.prefs, name)
+}
+
+// AddExtension installs the extension packaged at path. Its ID is read from
+// the browser_specific_settings, or applications, key of its manifest.json.
+func (p *Profile) AddExtension(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.Name != "manifest.json" {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+
+		var manifest struct {
+			BrowserSpecificSettings struct {
+				Gecko struct {
+					ID string `json:"id"`
+				} `json:"gecko"`
+			} `json:"browser_specific_settings"`
+			Applications struct {
+				Gecko struct {
+					ID string `json:"id"`
+				} `json:"gecko"`
+			} `json:"applications"`
+		}
+
+		if err := json.NewDecoder(rc).Decode(&manifest); err != nil {
+			return fmt.Errorf("%v: manifest.json: %v", path, err)
+		}
+
+		id := manifest.BrowserSpecificSettings.Gecko.ID
+		if id == "" {
+			id = manifest.Applications.Gecko.ID
+		}
+
+		if id == "" {
+			return fmt.Errorf("%v: manifest.json has no extension ID", path)
+		}
+
+		p.extensions[id] = path
+		return nil
+	}
+
+	return fmt.Errorf("%v: no manifest.json", path)
+}
+
+// AddCertOverride accepts a certificate Firefox would otherwise refuse.
+func (p *Profile) AddCertOverride(o CertOverride) {
+	p.certOverrides = append(p.certOverrides, o)
+}
+
+// AddCookie adds a cookie, set by Apply.
+func (p *Profile) AddCookie(c marionette.Cookie) {
+	p.cookies = append(p.cookies, c)
+}
+
+// AddPermission adds a permission, set by Apply.
+func (p *Profile) AddPermission(perm Permission) {
+	p.permissions = append(p.permissions, perm)
+}
+
+// Write creates the profile in dir, which is created if needed.
+func (p *Profile) Write(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	prefs := p.prefs
+	if len(p.extensions) != 0 {
+		prefs = make(map[string]string, len(p.prefs)+2)
+		// load extensions installed in the profile.
+		prefs["extensions.autoDisableScopes"] = "0"
+		prefs["extensions.enabledScopes"] = "15"
+		for name, value := range p.prefs {
+			prefs[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(prefs))
+	for name := range prefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	for _, name := range names {
+		fmt.Fprintf(&b, "user_pref(%q, %v);\n", name, prefs[name])
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "user.js"), b.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	if len(p.extensions) != 0 {
+		if err := os.MkdirAll(filepath.Join(dir, "extensions"), 0755); err != nil {
+			return err
+		}
+	}
+
+	for id, path := range p.extensions {
+		if err := copyFile(path, filepath.Join(dir, "extensions", id+".xpi")); err != nil {
+			return err
+		}
+	}
+
+	if len(p.certOverrides) != 0 {
+		b.Reset()
+		b.WriteString("# PSM Certificate Override Settings file\n# This is a generated file!  Do not edit.\n")
+		for _, o := range p.certOverrides {
+			fmt.Fprintf(&b, "%v:%v:\tOID.2.16.840.1.101.3.4.2.1\t%v\t\n", o.Host, o.Port, o.Fingerprint)
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, "cert_override.txt"), b.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Encode returns the profile as a base64 encoded zip file, the format of the
+// profile field of moz:firefoxOptions.
+func (p *Profile) Encode() (string, error) {
+	dir, err := os.MkdirTemp("", "marionette-profile-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	if err := p.Write(dir); err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		f, err := w.Create(filepath.ToSlash(name))
+		if err != nil {
+			return err
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(f, src)
+		return err
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(b.Bytes()), nil
+}
+
+// FirefoxOptions returns the moz:firefoxOptions capability selecting p, to
+// pass to NewSession through a WebDriver server like geckodriver. Marionette
+// itself ignores it, launch Firefox with Options.Profile instead.
+func (p *Profile) FirefoxOptions() (*marionette.FirefoxOptions, error) {
+	profile, err := p.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return &marionette.FirefoxOptions{Profile: profile}, nil
+}
+
+// applyScript adds the cookies and permissions in arguments[0] and
+// arguments[1], from the chrome context.
+const applyScript = `
+let [cookies, permissions] = arguments;
+let sameSite = {Lax: Ci.nsICookie.SAMESITE_LAX, Strict: Ci.nsICookie.SAMESITE_STRICT};
+for (let c of cookies) {
+  Services.cookies.add(c.domain, c.path || "/", c.name, c.value, c.secure, c.httpOnly, !c.expiry,
+    c.expiry || 2147483647, {}, sameSite[c.sameSite] || Ci.nsICookie.SAMESITE_NONE,
+    c.secure ? Ci.nsICookie.SCHEME_HTTPS : Ci.nsICookie.SCHEME_HTTP);
+}
+for (let p of permissions) {
+  let principal = Services.scriptSecurityManager.createContentPrincipalFromOrigin(p.Origin);
+  Services.perms.addFromPrincipal(principal, p.Type,
+    p.Allow ? Services.perms.ALLOW_ACTION : Services.perms.DENY_ACTION);
+}
+`
+
+// Apply adds the cookies and permissions of p to the browser of the session of
+// c, from the chrome context.
+func (p *Profile) Apply(c *marionette.Client) error {
+	if len(p.cookies) == 0 && len(p.permissions) == 0 {
+		return nil
+	}
+
+	cookies := p.cookies
+	if cookies == nil {
+		cookies = []marionette.Cookie{}
+	}
+
+	permissions := p.permissions
+	if permissions == nil {
+		permissions = []Permission{}
+	}
+
+	return c.ExecuteChromeScriptInto(nil, applyScript, cookies, permissions)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
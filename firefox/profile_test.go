@@ -0,0 +1,166 @@
+package firefox
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	marionette "github.com/njasm/marionette_client"
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func writeExtension(t *testing.T, manifest string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ext.xpi")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+	m, _ := w.Create("manifest.json")
+	io.WriteString(m, manifest)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestProfileWrite(t *testing.T) {
+	p := NewProfile()
+	p.SetBoolPref("browser.tabs.warnOnClose", true)
+	p.SetIntPref("dom.max_script_run_time", 0)
+	p.SetStringPref("intl.accept_languages", `en-US, "pt"`)
+	p.ClearPref("app.update.disabledForTesting")
+	p.AddCertOverride(CertOverride{"localhost", 8443, "AA:BB"})
+
+	if err := p.AddExtension(writeExtension(t, `{"browser_specific_settings":{"gecko":{"id":"ext@example.com"}}}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.AddExtension(writeExtension(t, `{"name":"no id"}`)); err == nil {
+		t.Fatal("expected an error for an extension without ID")
+	}
+
+	dir := t.TempDir()
+	if err := p.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	prefs, _ := os.ReadFile(filepath.Join(dir, "user.js"))
+	for _, line := range []string{
+		`user_pref("browser.tabs.warnOnClose", true);`,
+		`user_pref("dom.max_script_run_time", 0);`,
+		`user_pref("intl.accept_languages", "en-US, \"pt\"");`,
+		`user_pref("extensions.autoDisableScopes", 0);`,
+	} {
+		if !strings.Contains(string(prefs), line+"\n") {
+			t.Errorf("expected %v in user.js, got\n%s", line, prefs)
+		}
+	}
+
+	if strings.Contains(string(prefs), "app.update.disabledForTesting") {
+		t.Errorf("cleared preference in user.js\n%s", prefs)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "extensions", "ext@example.com.xpi")); err != nil {
+		t.Error(err)
+	}
+
+	overrides, _ := os.ReadFile(filepath.Join(dir, "cert_override.txt"))
+	if !strings.Contains(string(overrides), "localhost:8443:\tOID.2.16.840.1.101.3.4.2.1\tAA:BB\t\n") {
+		t.Errorf("unexpected cert_override.txt\n%s", overrides)
+	}
+}
+
+func TestProfileEncode(t *testing.T) {
+	p := NewProfile()
+	p.SetIntPref("marionette.port", 2829)
+
+	opts, err := p.FirefoxOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(opts.Profile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.File) != 1 || r.File[0].Name != "user.js" {
+		t.Fatalf("unexpected profile files %v", r.File)
+	}
+
+	caps, _ := json.Marshal(&marionette.Capabilities{FirefoxOptions: opts})
+	if !strings.Contains(string(caps), `"moz:firefoxOptions":{"profile":"`+opts.Profile+`"}`) {
+		t.Fatalf("unexpected capabilities %s", caps)
+	}
+}
+
+func TestProfileApply(t *testing.T) {
+	s := marionettetest.NewServer()
+	defer s.Close()
+	s.HandleValue("getContext", "content")
+	s.HandleValue("setContext", nil)
+	s.HandleValue("executeScript", nil)
+
+	c := marionette.NewClient()
+	if err := c.Connect(s.Host(), s.Port()); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	p := NewProfile()
+	p.AddCookie(marionette.Cookie{Name: "a", Value: "1", Domain: "example.com"})
+	p.AddPermission(Permission{"https://example.com", "geo", true})
+	if err := p.Apply(c); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, cmd := range s.Commands() {
+		names = append(names, cmd.Name)
+	}
+
+	if strings.Join(names, ",") != "getContext,setContext,executeScript,setContext" {
+		t.Fatalf("unexpected commands %v", names)
+	}
+
+	var script struct {
+		Args []json.RawMessage
+	}
+	s.Received("executeScript")[0].Decode(&script)
+	if len(script.Args) != 2 || !strings.Contains(string(script.Args[0]), `"example.com"`) || !strings.Contains(string(script.Args[1]), `"geo"`) {
+		t.Fatalf("unexpected script arguments %s", script.Args)
+	}
+}
+
+func TestLaunchProfile(t *testing.T) {
+	profile := NewProfile()
+	profile.SetStringPref("browser.startup.homepage", "about:blank")
+
+	p, err := Launch(context.Background(), &Options{Binary: os.Args[0], Env: []string{"FAKE_FIREFOX=quit"}, Profile: profile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	prefs, _ := os.ReadFile(filepath.Join(p.ProfileDir, "user.js"))
+	if !strings.Contains(string(prefs), `user_pref("browser.startup.homepage", "about:blank");`) {
+		t.Fatalf("profile preferences missing from user.js\n%s", prefs)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/njasm/marionette_client
+
+go 1.18
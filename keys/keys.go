@@ -0,0 +1,212 @@
+// Package keys provides the WebDriver code points of the keys without a
+// character, like Enter or the arrows, and helpers to type them with
+// WebElement.SendKeys, Client.SendKeysToDialog and Actions.
+//
+//	element.SendKeys("marionette" + keys.Enter)
+//	element.SendKeys(keys.Chord(keys.Control, "a") + keys.Delete)
+package keys
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Keys in the Unicode private use area, as defined by the WebDriver
+// specification.
+const (
+	Null       = "\ue000" // releases the modifiers held by SendKeys
+	Cancel     = "\ue001"
+	Help       = "\ue002"
+	Backspace  = "\ue003"
+	Tab        = "\ue004"
+	Clear      = "\ue005"
+	Return     = "\ue006"
+	Enter      = "\ue007"
+	Shift      = "\ue008"
+	Control    = "\ue009"
+	Alt        = "\ue00a"
+	Pause      = "\ue00b"
+	Escape     = "\ue00c"
+	Space      = "\ue00d"
+	PageUp     = "\ue00e"
+	PageDown   = "\ue00f"
+	End        = "\ue010"
+	Home       = "\ue011"
+	ArrowLeft  = "\ue012"
+	ArrowUp    = "\ue013"
+	ArrowRight = "\ue014"
+	ArrowDown  = "\ue015"
+	Insert     = "\ue016"
+	Delete     = "\ue017"
+	Semicolon  = "\ue018"
+	Equals     = "\ue019"
+
+	Numpad0   = "\ue01a"
+	Numpad1   = "\ue01b"
+	Numpad2   = "\ue01c"
+	Numpad3   = "\ue01d"
+	Numpad4   = "\ue01e"
+	Numpad5   = "\ue01f"
+	Numpad6   = "\ue020"
+	Numpad7   = "\ue021"
+	Numpad8   = "\ue022"
+	Numpad9   = "\ue023"
+	Multiply  = "\ue024"
+	Add       = "\ue025"
+	Separator = "\ue026"
+	Subtract  = "\ue027"
+	Decimal   = "\ue028"
+	Divide    = "\ue029"
+
+	F1  = "\ue031"
+	F2  = "\ue032"
+	F3  = "\ue033"
+	F4  = "\ue034"
+	F5  = "\ue035"
+	F6  = "\ue036"
+	F7  = "\ue037"
+	F8  = "\ue038"
+	F9  = "\ue039"
+	F10 = "\ue03a"
+	F11 = "\ue03b"
+	F12 = "\ue03c"
+
+	Meta           = "\ue03d"
+	ZenkakuHankaku = "\ue040"
+
+	RightShift     = "\ue050"
+	RightControl   = "\ue051"
+	RightAlt       = "\ue052"
+	RightMeta      = "\ue053"
+	NumpadPageUp   = "\ue054"
+	NumpadPageDown = "\ue055"
+	NumpadEnd      = "\ue056"
+	NumpadHome     = "\ue057"
+	NumpadLeft     = "\ue058"
+	NumpadUp       = "\ue059"
+	NumpadRight    = "\ue05a"
+	NumpadDown     = "\ue05b"
+	NumpadInsert   = "\ue05c"
+	NumpadDelete   = "\ue05d"
+
+	Command = Meta // macOS names
+	Option  = Alt
+)
+
+// modifiers are held down by SendKeys until Null or the end of the keys.
+var modifiers = map[string]bool{
+	Shift: true, Control: true, Alt: true, Meta: true,
+	RightShift: true, RightControl: true, RightAlt: true, RightMeta: true,
+}
+
+// IsModifier reports whether key is Shift, Control, Alt or Meta, on either
+// side.
+func IsModifier(key string) bool {
+	return modifiers[key]
+}
+
+// Chord returns keys typed while the modifiers among them are held, and
+// released at the end:
+//
+//	keys.Chord(keys.Control, keys.Shift, "t") // Ctrl+Shift+T
+func Chord(keys ...string) string {
+	return strings.Join(keys, "") + Null
+}
+
+// Split splits s into the keys typed one after the other: grapheme clusters,
+// so that a character with combining marks, an emoji sequence or a flag is
+// typed as one key. Surrogate pairs, encoded separately as in CESU-8, are
+// joined, unpaired surrogates and invalid UTF-8 become U+FFFD.
+func Split(s string) []string {
+	runes := decode(s)
+	var keys []string
+	for i := 0; i < len(runes); {
+		j := i + 1
+		for j < len(runes) && extends(runes, i, j) {
+			j++
+		}
+
+		keys = append(keys, string(runes[i:j]))
+		i = j
+	}
+
+	return keys
+}
+
+const zwj = '\u200d'
+
+// extends reports whether runes[j] belongs to the cluster starting at
+// runes[i].
+func extends(runes []rune, i, j int) bool {
+	r, prev := runes[j], runes[j-1]
+	switch {
+	case prev == '\r' && r == '\n':
+		return true
+	case prev == '\r' || prev == '\n' || r == '\r' || r == '\n':
+		return false
+	case prev == zwj:
+		return true
+	case r == zwj, unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xfe00 && r <= 0xfe0f, r >= 0xe0100 && r <= 0xe01ef: // variation selectors
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // emoji skin tone modifiers
+		return true
+	case r >= 0xe0020 && r <= 0xe007f: // tags, of subdivision flags
+		return true
+	case regional(r) && regional(prev):
+		// flags are pairs of regional indicators
+		n := 0
+		for k := j - 1; k >= i && regional(runes[k]); k-- {
+			n++
+		}
+
+		return n%2 == 1
+	}
+
+	return false
+}
+
+func regional(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// decode returns the code points of s, joining the halves of surrogate pairs
+// encoded one by one.
+func decode(s string) []rune {
+	var runes []rune
+	for len(s) > 0 {
+		r, n := surrogate(s)
+		if r != 0 {
+			if r2, n2 := surrogate(s[n:]); utf16.IsSurrogate(r) && r2 != 0 {
+				if pair := utf16.DecodeRune(r, r2); pair != utf8.RuneError {
+					runes = append(runes, pair)
+					s = s[n+n2:]
+					continue
+				}
+			}
+
+			runes = append(runes, utf8.RuneError)
+			s = s[n:]
+			continue
+		}
+
+		r, n = utf8.DecodeRuneInString(s)
+		runes = append(runes, r)
+		s = s[n:]
+	}
+
+	return runes
+}
+
+// surrogate decodes the UTF-16 surrogate encoded, like a code point, in the
+// first 3 bytes of s. It returns 0 if there is none.
+func surrogate(s string) (rune, int) {
+	if len(s) < 3 || s[0] != 0xed || s[1] < 0xa0 || s[1] > 0xbf || s[2]&0xc0 != 0x80 {
+		return 0, 0
+	}
+
+	return rune(s[0]&0x0f)<<12 | rune(s[1]&0x3f)<<6 | rune(s[2]&0x3f), 3
+}
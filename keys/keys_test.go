@@ -0,0 +1,40 @@
+package keys
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		keys []string
+	}{
+		{"", nil},
+		{"abc", []string{"a", "b", "c"}},
+		{"a" + Enter + Tab, []string{"a", Enter, Tab}},
+		{"e\u0301t\u00e9", []string{"e\u0301", "t", "\u00e9"}},                                                                         // combining acute accent
+		{"\U0001F44D\U0001F3FD!", []string{"\U0001F44D\U0001F3FD", "!"}},                                                               // skin tone
+		{"\U0001F468\u200d\U0001F469\u200d\U0001F467", []string{"\U0001F468\u200d\U0001F469\u200d\U0001F467"}},                         // family
+		{"\U0001F1F5\U0001F1F9\U0001F1E7\U0001F1F7\U0001F1FA", []string{"\U0001F1F5\U0001F1F9", "\U0001F1E7\U0001F1F7", "\U0001F1FA"}}, // flags
+		{"\u2764\ufe0f", []string{"\u2764\ufe0f"}},                                                                                     // variation selector
+		{"a\r\nb", []string{"a", "\r\n", "b"}},
+		{"\xed\xa0\xbd\xed\xb8\x80x", []string{"\U0001F600", "x"}}, // CESU-8 surrogate pair
+		{"\xed\xa0\xbdx", []string{"\ufffd", "x"}},                 // unpaired high surrogate
+		{"\xffx", []string{"\ufffd", "x"}},
+	} {
+		if got := Split(tc.in); !reflect.DeepEqual(got, tc.keys) {
+			t.Errorf("Split(%+q): expected %+q, got %+q", tc.in, tc.keys, got)
+		}
+	}
+}
+
+func TestChord(t *testing.T) {
+	if got := Chord(Control, Shift, "t"); got != Control+Shift+"t"+Null {
+		t.Fatalf("unexpected chord %+q", got)
+	}
+
+	if !IsModifier(RightAlt) || !IsModifier(Command) || IsModifier(Enter) || IsModifier("a") {
+		t.Fatal("unexpected modifiers")
+	}
+}
@@ -0,0 +1,434 @@
+// Package marionettetest provides a scriptable, in-process Marionette server
+// for testing clients without a running Firefox.
+//
+// The server speaks the Marionette handshake and the length-prefixed protocol
+// framing. Tests register a handler per command name and inspect the commands
+// the server received:
+//
+//	s := marionettetest.NewServer()
+//	defer s.Close()
+//
+//	s.HandleValue("getTitle", "Example Domain")
+//	client.Connect(s.Host(), s.Port())
+package marionettetest
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+// ElementKey is the key of web element references on the wire.
+const ElementKey = "element-6066-11e4-a52e-4f735466cecf"
+
+// Command is a command received by the server.
+type Command struct {
+	ID     int
+	Name   string
+	Params json.RawMessage
+}
+
+// Decode unmarshals the command parameters into v.
+func (c Command) Decode(v interface{}) error {
+	if len(c.Params) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(c.Params, v)
+}
+
+// HandlerFunc answers a command. The returned value is sent as the command
+// result; a returned *Error is sent as a WebDriver error, any other error as
+// an "unknown error".
+type HandlerFunc func(c Command) (interface{}, error)
+
+// Error is a WebDriver error sent back to the client.
+type Error struct {
+	Type       string
+	Message    string
+	Stacktrace string
+}
+
+func (e *Error) Error() string {
+	return e.Type + ": " + e.Message
+}
+
+// Server is a fake Marionette server listening on a loopback address.
+// Commands are answered concurrently, each from its own goroutine, so a handler
+// may block without holding back other commands. With Protocol 2, whose
+// responses have no message ID, they are answered in order of arrival.
+type Server struct {
+	// Sent in the handshake. Change them before Start.
+	ApplicationType string
+	Protocol        int
+
+	l      net.Listener
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	h      map[string]HandlerFunc
+	cmds   []Command
+	conns  map[net.Conn]bool
+	closed bool
+}
+
+// NewServer starts and returns a new Server speaking protocol version 3.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := NewUnstartedServer()
+	s.Start()
+	return s
+}
+
+// NewUnstartedServer returns a new Server, with the default handlers
+// registered, that is not listening yet.
+func NewUnstartedServer() *Server {
+	s := &Server{
+		ApplicationType: "gecko",
+		Protocol:        3,
+		h:               make(map[string]HandlerFunc),
+		conns:           make(map[net.Conn]bool),
+	}
+
+	s.Handle("newSession", func(c Command) (interface{}, error) {
+		caps := map[string]interface{}{
+			"browserName":    "firefox",
+			"browserVersion": "0.0",
+			"platformName":   "linux",
+		}
+
+		// echo the requested capabilities, as Marionette does.
+		var params struct {
+			Capabilities map[string]interface{} `json:"capabilities"`
+		}
+
+		c.Decode(&params)
+		for name, v := range params.Capabilities {
+			if name != "alwaysMatch" && name != "firstMatch" && name != "browserVersion" {
+				caps[name] = v
+			}
+		}
+
+		return map[string]interface{}{
+			"sessionId":    "00000000-0000-0000-0000-000000000000",
+			"capabilities": caps,
+		}, nil
+	})
+	s.Handle("deleteSession", func(c Command) (interface{}, error) {
+		return nil, nil
+	})
+
+	return s
+}
+
+// Start starts listening on a random loopback port.
+func (s *Server) Start() {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("marionettetest: failed to listen: %v", err))
+	}
+
+	s.Serve(l)
+}
+
+// Serve accepts connections on l in the background. It allows a server to
+// listen on a fixed port.
+func (s *Server) Serve(l net.Listener) {
+	s.l = l
+	s.wg.Add(1)
+	go s.accept()
+}
+
+// Addr returns the address the server listens on, as host:port.
+func (s *Server) Addr() string {
+	return s.l.Addr().String()
+}
+
+// Host returns the host the server listens on.
+func (s *Server) Host() string {
+	return s.l.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	return s.l.Addr().(*net.TCPAddr).Port
+}
+
+// Handle registers the handler for the named command, replacing any previous
+// one. Commands without a handler are answered with an "unknown command" error.
+func (s *Server) Handle(name string, h HandlerFunc) {
+	s.mu.Lock()
+	s.h[name] = h
+	s.mu.Unlock()
+}
+
+// HandleValue answers the named command with {"value": v}, the shape Marionette
+// uses for most results.
+func (s *Server) HandleValue(name string, v interface{}) {
+	s.Handle(name, func(c Command) (interface{}, error) {
+		return Value(v), nil
+	})
+}
+
+// HandleError answers the named command with a WebDriver error.
+func (s *Server) HandleError(name string, errorType string, message string) {
+	s.Handle(name, func(c Command) (interface{}, error) {
+		return nil, &Error{Type: errorType, Message: message}
+	})
+}
+
+// Commands returns the commands received so far, in the order they were read.
+func (s *Server) Commands() []Command {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Command(nil), s.cmds...)
+}
+
+// Received returns the received commands with the given name.
+func (s *Server) Received(name string) []Command {
+	var cmds []Command
+	for _, c := range s.Commands() {
+		if c.Name == name {
+			cmds = append(cmds, c)
+		}
+	}
+
+	return cmds
+}
+
+// Reset forgets the commands received so far.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.cmds = nil
+	s.mu.Unlock()
+}
+
+// Close stops listening, closes every connection and waits for them to finish.
+// Handlers still blocked are left to return on their own.
+func (s *Server) Close() {
+	s.l.Close()
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Value wraps v as {"value": v}.
+func Value(v interface{}) map[string]interface{} {
+	return map[string]interface{}{"value": v}
+}
+
+// Element returns the wire reference of the web element with the given id.
+func Element(id string) map[string]string {
+	return map[string]string{ElementKey: id}
+}
+
+func (s *Server) accept() {
+	defer s.wg.Done()
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			c.Close()
+			return
+		}
+
+		s.conns[c] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(c)
+	}
+}
+
+func (s *Server) serve(c net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		c.Close()
+	}()
+
+	var wmu sync.Mutex
+	send := func(v interface{}) {
+		b, err := json.Marshal(v)
+		if err != nil {
+			panic(fmt.Sprintf("marionettetest: can't marshal response: %v", err))
+		}
+
+		wmu.Lock()
+		c.Write([]byte(strconv.Itoa(len(b)) + ":" + string(b)))
+		wmu.Unlock()
+	}
+
+	send(map[string]interface{}{
+		"applicationType":    s.ApplicationType,
+		"marionetteProtocol": s.Protocol,
+	})
+
+	r := bufio.NewReader(c)
+	for id := 1; ; id++ {
+		buf, err := readFrame(r)
+		if err != nil {
+			return
+		}
+
+		cmd, err := s.decode(buf, id)
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.cmds = append(s.cmds, cmd)
+		h := s.h[cmd.Name]
+		s.mu.Unlock()
+
+		handle := func() {
+			if h == nil {
+				send(s.encode(cmd, nil, &Error{Type: "unknown command", Message: cmd.Name}))
+				return
+			}
+
+			v, err := h(cmd)
+			send(s.encode(cmd, v, err))
+		}
+
+		// protocol 2 responses have no message ID, they must be sent in order.
+		if s.Protocol < 3 {
+			handle()
+			continue
+		}
+
+		go handle()
+	}
+}
+
+// decode parses a command. Protocol 2 commands have no message ID, they are
+// numbered in order of arrival from 1.
+func (s *Server) decode(buf []byte, id int) (Command, error) {
+	if s.Protocol < 3 {
+		var m struct {
+			Name       string          `json:"name"`
+			Parameters json.RawMessage `json:"parameters"`
+		}
+
+		if err := json.Unmarshal(buf, &m); err != nil {
+			return Command{}, err
+		}
+
+		if m.Name == "" {
+			return Command{}, errors.New("marionettetest: malformed command")
+		}
+
+		return Command{ID: id, Name: m.Name, Params: m.Parameters}, nil
+	}
+
+	var m []json.RawMessage
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return Command{}, err
+	}
+
+	if len(m) != 4 {
+		return Command{}, errors.New("marionettetest: malformed command")
+	}
+
+	cmd := Command{Params: m[3]}
+	if err := json.Unmarshal(m[1], &cmd.ID); err != nil {
+		return Command{}, err
+	}
+
+	if err := json.Unmarshal(m[2], &cmd.Name); err != nil {
+		return Command{}, err
+	}
+
+	return cmd, nil
+}
+
+func (s *Server) encode(cmd Command, v interface{}, err error) interface{} {
+	if s.Protocol < 3 {
+		return s.encodeV2(v, err)
+	}
+
+	if err == nil {
+		return []interface{}{1, cmd.ID, nil, v}
+	}
+
+	e, ok := err.(*Error)
+	if !ok {
+		e = &Error{Type: "unknown error", Message: err.Error()}
+	}
+
+	var stacktrace interface{}
+	if e.Stacktrace != "" {
+		stacktrace = e.Stacktrace
+	}
+
+	return []interface{}{1, cmd.ID, map[string]interface{}{
+		"error":      e.Type,
+		"message":    e.Message,
+		"stacktrace": stacktrace,
+	}, nil}
+}
+
+// encodeV2 returns a protocol 2 response: the fields of the result object, or
+// the error, along with "from".
+func (s *Server) encodeV2(v interface{}, err error) interface{} {
+	m := map[string]interface{}{}
+	if err != nil {
+		e, ok := err.(*Error)
+		if !ok {
+			e = &Error{Type: "unknown error", Message: err.Error()}
+		}
+
+		m["error"] = map[string]interface{}{
+			"error":      e.Type,
+			"message":    e.Message,
+			"stacktrace": e.Stacktrace,
+		}
+	} else if v != nil {
+		b, err := json.Marshal(v)
+		if err != nil {
+			panic(fmt.Sprintf("marionettetest: can't marshal response: %v", err))
+		}
+
+		if json.Unmarshal(b, &m) != nil {
+			m = map[string]interface{}{"value": v}
+		}
+	}
+
+	m["from"] = "root"
+	return m
+}
+
+func readFrame(r *bufio.Reader) ([]byte, error) {
+	size, err := r.ReadString(':')
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(size[:len(size)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
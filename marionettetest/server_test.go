@@ -0,0 +1,100 @@
+package marionettetest
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	s.HandleValue("getTitle", "Example Domain")
+
+	c, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	buf, err := readFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var hello map[string]interface{}
+	json.Unmarshal(buf, &hello)
+	if hello["applicationType"] != "gecko" || hello["marionetteProtocol"] != float64(3) {
+		t.Fatalf("unexpected handshake %s", buf)
+	}
+
+	for _, tc := range []struct {
+		cmd      string
+		response string
+	}{
+		{`[0,1,"getTitle",{}]`, `[1,1,null,{"value":"Example Domain"}]`},
+		{`[0,2,"nope",null]`, `[1,2,{"error":"unknown command","message":"nope","stacktrace":null},null]`},
+	} {
+		c.Write([]byte(strconv.Itoa(len(tc.cmd)) + ":" + tc.cmd))
+		buf, err := readFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(buf) != tc.response {
+			t.Fatalf("expected %s, got %s", tc.response, buf)
+		}
+	}
+
+	cmds := s.Commands()
+	if len(cmds) != 2 || cmds[0].Name != "getTitle" || cmds[1].ID != 2 {
+		t.Fatalf("unexpected commands %#v", cmds)
+	}
+}
+
+func TestServerProtocolV2(t *testing.T) {
+	s := NewUnstartedServer()
+	s.Protocol = 2
+	s.Start()
+	defer s.Close()
+	s.HandleValue("getTitle", "Example Domain")
+	s.HandleValue("get", nil)
+
+	c, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	r := bufio.NewReader(c)
+	if buf, err := readFrame(r); err != nil || string(buf) != `{"applicationType":"gecko","marionetteProtocol":2}` {
+		t.Fatalf("unexpected handshake %s %v", buf, err)
+	}
+
+	for _, tc := range []struct {
+		cmd      string
+		response string
+	}{
+		{`{"name":"getTitle","parameters":{}}`, `{"from":"root","value":"Example Domain"}`},
+		{`{"name":"get","parameters":{"url":"about:blank"}}`, `{"from":"root","value":null}`},
+		{`{"name":"nope","parameters":null}`, `{"error":{"error":"unknown command","message":"nope","stacktrace":""},"from":"root"}`},
+	} {
+		c.Write([]byte(strconv.Itoa(len(tc.cmd)) + ":" + tc.cmd))
+		buf, err := readFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(buf) != tc.response {
+			t.Fatalf("expected %s, got %s", tc.response, buf)
+		}
+	}
+
+	cmds := s.Commands()
+	if len(cmds) != 3 || cmds[1].Name != "get" || cmds[1].ID != 2 || string(cmds[1].Params) != `{"url":"about:blank"}` {
+		t.Fatalf("unexpected commands %#v", cmds)
+	}
+}
@@ -0,0 +1,258 @@
+package page
+
+import (
+	"errors"
+	"sync"
+
+	marionette "github.com/njasm/marionette_client"
+)
+
+// scope is where elements are looked up: the root finder of the page, or the
+// element of a component.
+type scope struct {
+	finder  marionette.Finder
+	element *Element
+}
+
+func (s *scope) resolve() (marionette.Finder, error) {
+	if s.element == nil {
+		return s.finder, nil
+	}
+
+	e, err := s.element.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// invalidate forgets the elements the scope was resolved with.
+func (s *scope) invalidate() {
+	if s.element != nil {
+		s.element.invalidate()
+	}
+}
+
+// find looks up an element within s, again from a fresh scope if the scope
+// went stale.
+func (s *scope) find(by marionette.By, value string) (*marionette.WebElement, error) {
+	f, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := f.FindElement(by, value)
+	if errors.Is(err, marionette.ErrStaleElementReference) {
+		s.invalidate()
+		if f, err = s.resolve(); err != nil {
+			return nil, err
+		}
+
+		e, err = f.FindElement(by, value)
+	}
+
+	return e, err
+}
+
+// findAll looks up the elements within s, again from a fresh scope if the
+// scope went stale.
+func (s *scope) findAll(by marionette.By, value string) ([]*marionette.WebElement, error) {
+	f, err := s.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := f.FindElements(by, value)
+	if errors.Is(err, marionette.ErrStaleElementReference) {
+		s.invalidate()
+		if f, err = s.resolve(); err != nil {
+			return nil, err
+		}
+
+		e, err = f.FindElements(by, value)
+	}
+
+	return e, err
+}
+
+// Element is an element of a page object, looked up when first used and
+// looked up again when it went stale.
+type Element struct {
+	scope *scope
+	by    marionette.By
+	value string
+
+	mu sync.Mutex
+	e  *marionette.WebElement
+}
+
+// Get returns the web element, looking it up if needed.
+func (e *Element) Get() (*marionette.WebElement, error) {
+	if e == nil || e.scope == nil {
+		return nil, errors.New("page: Element not bound")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.e != nil {
+		return e.e, nil
+	}
+
+	found, err := e.scope.find(e.by, e.value)
+	if err != nil {
+		return nil, err
+	}
+
+	e.e = found
+	return found, nil
+}
+
+func (e *Element) invalidate() {
+	e.mu.Lock()
+	e.e = nil
+	e.mu.Unlock()
+
+	e.scope.invalidate()
+}
+
+// Do runs fn with the web element. If the element went stale, it is looked
+// up again and fn runs a second time.
+func (e *Element) Do(fn func(*marionette.WebElement) error) error {
+	we, err := e.Get()
+	if err != nil {
+		return err
+	}
+
+	err = fn(we)
+	if !errors.Is(err, marionette.ErrStaleElementReference) {
+		return err
+	}
+
+	e.invalidate()
+	if we, err = e.Get(); err != nil {
+		return err
+	}
+
+	return fn(we)
+}
+
+// Present reports whether the element is on the page.
+func (e *Element) Present() (bool, error) {
+	if e == nil || e.scope == nil {
+		return false, errors.New("page: Element not bound")
+	}
+
+	e.mu.Lock()
+	e.e = nil
+	e.mu.Unlock()
+
+	_, err := e.Get()
+	if errors.Is(err, marionette.ErrNoSuchElement) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+func (e *Element) Click() error {
+	return e.Do(func(we *marionette.WebElement) error { return we.Click() })
+}
+
+func (e *Element) SendKeys(keys string) error {
+	return e.Do(func(we *marionette.WebElement) error { return we.SendKeys(keys) })
+}
+
+func (e *Element) Clear() error {
+	return e.Do(func(we *marionette.WebElement) error { return we.Clear() })
+}
+
+func (e *Element) Text() (text string, err error) {
+	err = e.Do(func(we *marionette.WebElement) error {
+		text, err = we.Text()
+		return err
+	})
+
+	return text, err
+}
+
+func (e *Element) Attribute(name string) (value string, err error) {
+	err = e.Do(func(we *marionette.WebElement) error {
+		value, err = we.Attribute(name)
+		return err
+	})
+
+	return value, err
+}
+
+func (e *Element) Displayed() (displayed bool, err error) {
+	err = e.Do(func(we *marionette.WebElement) error {
+		displayed, err = we.Displayed()
+		return err
+	})
+
+	return displayed, err
+}
+
+func (e *Element) Enabled() (enabled bool, err error) {
+	err = e.Do(func(we *marionette.WebElement) error {
+		enabled, err = we.Enabled()
+		return err
+	})
+
+	return enabled, err
+}
+
+func (e *Element) Selected() (selected bool, err error) {
+	err = e.Do(func(we *marionette.WebElement) error {
+		selected, err = we.Selected()
+		return err
+	})
+
+	return selected, err
+}
+
+// FindElement looks up an element within e, so that an Element is a
+// marionette.Finder, e.g. for marionette.Wait.
+func (e *Element) FindElement(by marionette.By, value string) (found *marionette.WebElement, err error) {
+	err = e.Do(func(we *marionette.WebElement) error {
+		found, err = we.FindElement(by, value)
+		return err
+	})
+
+	return found, err
+}
+
+// FindElements looks up the elements within e.
+func (e *Element) FindElements(by marionette.By, value string) (found []*marionette.WebElement, err error) {
+	err = e.Do(func(we *marionette.WebElement) error {
+		found, err = we.FindElements(by, value)
+		return err
+	})
+
+	return found, err
+}
+
+// Elements are the elements of a page object matching a locator. They are
+// looked up on every call.
+type Elements struct {
+	scope *scope
+	by    marionette.By
+	value string
+}
+
+// All returns the matching web elements.
+func (l Elements) All() ([]*marionette.WebElement, error) {
+	if l.scope == nil {
+		return nil, errors.New("page: Elements not bound")
+	}
+
+	return l.scope.findAll(l.by, l.value)
+}
+
+// Len returns the number of matching elements.
+func (l Elements) Len() (int, error) {
+	all, err := l.All()
+	return len(all), err
+}
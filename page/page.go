@@ -0,0 +1,194 @@
+// Package page declares page objects as structs whose fields are located by
+// tags, and binds them to a client or an element.
+//
+//	type SearchForm struct {
+//		*page.Element // the form itself
+//		Query  *page.Element `name:"q"`
+//		Submit *page.Element `css:"button[type=submit]"`
+//	}
+//
+//	type SearchPage struct {
+//		Form    SearchForm    `css:"form#search"`
+//		Results page.Elements `css:".result a"`
+//	}
+//
+//	var p SearchPage
+//	if err := page.Bind(&p, client); err != nil {
+//		// the struct is invalid
+//	}
+//
+//	p.Form.Query.SendKeys("marionette")
+//	p.Form.Submit.Click()
+//	links, err := p.Results.All()
+//
+// Elements are looked up when first used, and looked up again when they went
+// stale, e.g. after the page re-rendered them.
+//
+// The tags are the locator strategies: id, name, class, tag, css, link,
+// partial_link and xpath. Fields of type *Element locate one element, fields
+// of type Elements all the matching ones. A tagged struct field, or pointer to
+// a struct, is a component: its fields are located within the element of its
+// tag, which its embedded *Element field, if any, refers to. Untagged struct
+// fields with located fields share the scope of their parent; other fields
+// are left alone.
+package page
+
+import (
+	"fmt"
+	"reflect"
+
+	marionette "github.com/njasm/marionette_client"
+)
+
+var strategies = map[string]marionette.By{
+	"id":           marionette.ID,
+	"name":         marionette.NAME,
+	"class":        marionette.CLASS_NAME,
+	"tag":          marionette.TAG_NAME,
+	"css":          marionette.CSS_SELECTOR,
+	"link":         marionette.LINK_TEXT,
+	"partial_link": marionette.PARTIAL_LINK_TEXT,
+	"xpath":        marionette.XPATH,
+}
+
+var (
+	elementType  = reflect.TypeOf((*Element)(nil))
+	elementsType = reflect.TypeOf(Elements{})
+)
+
+// Bind binds the fields of the struct p points to, to the elements they
+// locate within root, a *marionette.Client for a whole page or a
+// *marionette.WebElement. Nothing is looked up until the fields are used.
+func Bind(p interface{}, root marionette.Finder) error {
+	v := reflect.ValueOf(p)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("page: Bind needs a pointer to a struct, not %T", p)
+	}
+
+	return bind(v.Elem(), &scope{finder: root}, map[reflect.Type]bool{})
+}
+
+// bind binds the fields of the struct v within s, and its embedded *Element
+// field to self. path holds the struct types being bound, to catch recursive
+// components.
+func bind(v reflect.Value, s *scope, path map[reflect.Type]bool, self ...*Element) error {
+	t := v.Type()
+	path[t] = true
+	defer delete(path, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+
+		by, value, tagged, err := locator(f)
+		if err != nil {
+			return fmt.Errorf("page: %v.%v: %v", t, f.Name, err)
+		}
+
+		fv := v.Field(i)
+		switch {
+		case f.Type == elementType && f.Anonymous && !tagged:
+			if len(self) > 0 {
+				fv.Set(reflect.ValueOf(self[0]))
+			}
+		case f.Type == elementType && tagged:
+			fv.Set(reflect.ValueOf(&Element{scope: s, by: by, value: value}))
+		case f.Type == elementsType && tagged:
+			fv.Set(reflect.ValueOf(Elements{scope: s, by: by, value: value}))
+		case structType(f.Type) != nil:
+			st := structType(f.Type)
+			if !tagged && (path[st] || !hasLocators(st, map[reflect.Type]bool{})) {
+				continue // not part of the page, e.g. an *http.Client or a link to another page
+			}
+
+			if path[st] {
+				return fmt.Errorf("page: %v.%v: recursive component %v", t, f.Name, st)
+			}
+
+			if f.Type.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					fv.Set(reflect.New(st))
+				}
+
+				fv = fv.Elem()
+			}
+
+			if !tagged {
+				if err := bind(fv, s, path, self...); err != nil {
+					return err
+				}
+
+				continue
+			}
+
+			e := &Element{scope: s, by: by, value: value}
+			if err := bind(fv, &scope{element: e}, path, e); err != nil {
+				return err
+			}
+		case tagged:
+			return fmt.Errorf("page: %v.%v: a located field must be a *page.Element, page.Elements or a struct, not %v", t, f.Name, f.Type)
+		}
+	}
+
+	return nil
+}
+
+// structType returns t, or the type t points to, if it is a struct, nil
+// otherwise.
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	return t
+}
+
+// hasLocators reports whether the struct t has a located field, itself or
+// through its untagged struct fields.
+func hasLocators(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] {
+		return false
+	}
+
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if _, _, tagged, err := locator(f); tagged || err != nil {
+			return true
+		}
+
+		if st := structType(f.Type); st != nil && hasLocators(st, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// locator returns the strategy and value of the tag of f, if it has one.
+func locator(f reflect.StructField) (by marionette.By, value string, tagged bool, err error) {
+	for name, strategy := range strategies {
+		v, ok := f.Tag.Lookup(name)
+		if !ok {
+			continue
+		}
+
+		if tagged {
+			return 0, "", false, fmt.Errorf("more than one locator")
+		}
+
+		by, value, tagged = strategy, v, true
+	}
+
+	return by, value, tagged, nil
+}
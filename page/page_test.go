@@ -0,0 +1,245 @@
+package page
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	marionette "github.com/njasm/marionette_client"
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+type searchForm struct {
+	*Element
+	Query  *Element `name:"q"`
+	Submit *Element `css:"button"`
+}
+
+type searchPage struct {
+	Form    searchForm `css:"form#search"`
+	Results Elements   `css:".result"`
+	Footer  struct {
+		Copyright *Element `id:"copyright"`
+	}
+	Sidebar *struct {
+		Links Elements `tag:"a"`
+	} `xpath:"//aside"`
+
+	title string
+}
+
+type linkedPage struct {
+	Heading *Element `tag:"h1"`
+	Next    *linkedPage
+	HTTP    *http.Client
+	Meta    struct{ Visits int }
+}
+
+type recursivePage struct {
+	Child *recursivePage `css:".child"`
+}
+
+// fakePage answers find commands with element ids made of the ids of the
+// start node and the value: "form#search", "form#search/q", ...
+func fakePage(t *testing.T) (*marionette.Client, *marionettetest.Server) {
+	s := marionettetest.NewServer()
+	c := marionette.NewClient()
+	if err := c.Connect(s.Host(), s.Port()); err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		c.Close()
+		s.Close()
+	})
+
+	id := func(cmd marionettetest.Command) string {
+		var p struct {
+			Value   string
+			Element string
+		}
+		cmd.Decode(&p)
+		if p.Element == "" {
+			return p.Value
+		}
+
+		return p.Element + "/" + p.Value
+	}
+
+	s.Handle("findElement", func(cmd marionettetest.Command) (interface{}, error) {
+		return marionettetest.Value(marionettetest.Element(id(cmd))), nil
+	})
+	s.Handle("findElements", func(cmd marionettetest.Command) (interface{}, error) {
+		return []interface{}{marionettetest.Element(id(cmd) + "[0]"), marionettetest.Element(id(cmd) + "[1]")}, nil
+	})
+
+	return c, s
+}
+
+func finds(s *marionettetest.Server) string {
+	var ids []string
+	for _, cmd := range s.Commands() {
+		if !strings.HasPrefix(cmd.Name, "find") {
+			continue
+		}
+
+		var p struct {
+			Value   string
+			Element string
+		}
+		cmd.Decode(&p)
+		if p.Element != "" {
+			p.Value = p.Element + "/" + p.Value
+		}
+
+		ids = append(ids, p.Value)
+	}
+
+	return strings.Join(ids, ",")
+}
+
+func TestBindFake(t *testing.T) {
+	c, s := fakePage(t)
+	s.HandleValue("sendKeysToElement", nil)
+	s.HandleValue("clickElement", nil)
+
+	var p searchPage
+	if err := Bind(&p, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.Commands()) != 0 {
+		t.Fatal("expected Bind not to look up elements")
+	}
+
+	if err := p.Form.Query.SendKeys("marionette"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Form.Submit.Click(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Form.Click(); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := p.Results.All()
+	if err != nil || len(results) != 2 || results[1].Id() != ".result[1]" {
+		t.Fatalf("got %v, %v", results, err)
+	}
+
+	links, err := p.Sidebar.Links.All()
+	if err != nil || links[0].Id() != "//aside/a[0]" {
+		t.Fatalf("got %v, %v", links, err)
+	}
+
+	if e, err := p.Footer.Copyright.Get(); err != nil || e.Id() != "copyright" {
+		t.Fatalf("got %v, %v", e, err)
+	}
+
+	want := "form#search,form#search/q,form#search/button,.result,//aside,//aside/a,copyright"
+	if got := finds(s); got != want {
+		t.Fatalf("expected lookups %v, got %v", want, got)
+	}
+
+	var clicks []string
+	for _, cmd := range s.Received("clickElement") {
+		var p map[string]string
+		cmd.Decode(&p)
+		clicks = append(clicks, p["id"])
+	}
+
+	if strings.Join(clicks, ",") != "form#search/button,form#search" {
+		t.Fatalf("unexpected clicks %v", clicks)
+	}
+}
+
+func TestStaleElementFake(t *testing.T) {
+	c, s := fakePage(t)
+
+	// the first element clicked went stale, along with the form
+	var mu sync.Mutex
+	stale := map[string]bool{"form#search/button": true}
+	s.Handle("clickElement", func(cmd marionettetest.Command) (interface{}, error) {
+		var p map[string]string
+		cmd.Decode(&p)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if stale[p["id"]] {
+			delete(stale, p["id"])
+			stale["form#search"] = true
+			return nil, &marionettetest.Error{Type: "stale element reference", Message: p["id"]}
+		}
+
+		return marionettetest.Value(nil), nil
+	})
+
+	var p searchPage
+	if err := Bind(&p, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Form.Submit.Click(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the button is found again within a fresh form
+	if got := finds(s); got != "form#search,form#search/button,form#search,form#search/button" {
+		t.Fatalf("unexpected lookups %v", got)
+	}
+}
+
+func TestBindErrors(t *testing.T) {
+	var p searchPage
+	if err := Bind(p, nil); err == nil {
+		t.Fatal("expected an error for a non pointer")
+	}
+
+	var bad struct {
+		Name string `css:"#name"`
+	}
+	if err := Bind(&bad, nil); err == nil {
+		t.Fatal("expected an error for a string field")
+	}
+
+	var twice struct {
+		E *Element `css:"#a" id:"a"`
+	}
+	if err := Bind(&twice, nil); err == nil {
+		t.Fatal("expected an error for two locators")
+	}
+}
+
+func TestBindOtherFields(t *testing.T) {
+	var p linkedPage
+	if err := Bind(&p, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Heading == nil || p.Next != nil || p.HTTP != nil {
+		t.Fatalf("expected only the located fields to be set, got %+v", p)
+	}
+
+	if err := Bind(&recursivePage{}, nil); err == nil {
+		t.Fatal("expected an error for a recursive component")
+	}
+}
+
+func TestUnboundElement(t *testing.T) {
+	var p searchPage
+	if _, err := p.Form.Query.Get(); err == nil {
+		t.Fatal("expected an error for a nil Element")
+	}
+
+	if err := (&Element{}).Click(); err == nil {
+		t.Fatal("expected an error for an unbound Element")
+	}
+
+	if _, err := (&Element{}).Present(); err == nil {
+		t.Fatal("expected an error for an unbound Element")
+	}
+}
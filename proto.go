@@ -1,6 +1,7 @@
 package marionette_client
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,9 +10,10 @@ import (
 )
 
 func NewDecoderEncoder(protoVersion int32) (DecoderEncoder, error) {
-	//if protoVersion == MARIONETTE_PROTOCOL_V2 {
-	//	return makeProto2Response(buf)
-	//}
+	if protoVersion == MARIONETTE_PROTOCOL_V2 {
+		return ProtoV2DecoderEncoder{}, nil
+	}
+
 	if protoVersion == MARIONETTE_PROTOCOL_V3 {
 		return ProtoV3DecoderEncoder{}, nil
 	}
@@ -72,25 +74,28 @@ func (e ProtoV3DecoderEncoder) Decode(buf []byte, r *Response) error {
 	}
 	//Debug only end
 
-	r.MessageID = int32(v[1].(float64))
+	if len(v) != 4 {
+		return errors.New("Malformed message: " + string(buf))
+	}
+
+	id, ok := v[1].(float64)
+	if !ok {
+		return errors.New("Malformed message ID: " + string(buf))
+	}
+
+	r.MessageID = int32(id)
 	r.Size = int32(len(buf))
 
 	// error found on response?
 	if v[2] != nil {
-		re := &DriverError{}
-		for key, value := range v[2].(map[string]interface{}) {
-			if key == "error" {
-				re.ErrorType = value.(string)
-			}
-
-			if key == "message" {
-				re.Message = value.(string)
-			}
+		b, err := json.Marshal(v[2])
+		if err != nil {
+			return err
+		}
 
-			if key == "stacktrace" && value != nil {
-				theTrace := value.(string)
-				re.Stacktrace = &theTrace
-			}
+		re := &DriverError{}
+		if err := json.Unmarshal(b, re); err != nil {
+			return err
 		}
 
 		return re
@@ -124,3 +129,116 @@ func (e ProtoV3DecoderEncoder) Decode(buf []byte, r *Response) error {
 
 	return nil
 }
+
+// ProtoV2DecoderEncoder speaks the protocol of older Gecko builds. Commands
+// are {"name": ..., "parameters": ...} objects and responses carry no message
+// ID: {"from": ..., "value": ...} or {"from": ..., "error": {...}}. Marionette
+// answers them in order, so the transport hands each response to the oldest
+// command waiting.
+type ProtoV2DecoderEncoder struct{}
+
+func (e ProtoV2DecoderEncoder) Encode(t Transporter, command string, values interface{}) ([]byte, error) {
+	message := map[string]interface{}{
+		"name":       command,
+		"parameters": values,
+	}
+
+	b, err := json.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	if RunningInDebugMode {
+		fmt.Println(string(b))
+	}
+
+	return []byte(strconv.Itoa(len(b)) + ":" + string(b)), nil
+}
+
+func (e ProtoV2DecoderEncoder) Decode(buf []byte, r *Response) error {
+	var v map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &v); err != nil {
+		return errors.New("Malformed message: " + string(buf))
+	}
+
+	//Debug only
+	if RunningInDebugMode {
+		if len(buf) >= 512 {
+			log.Println(string(buf)[0:512] + " - END - " + string(buf)[len(buf)-512:])
+		} else {
+			log.Println(string(buf))
+		}
+	}
+	//Debug only end
+
+	r.Size = int32(len(buf))
+
+	if raw, found := v["error"]; found && string(raw) != "null" {
+		re := &DriverError{}
+		if err := json.Unmarshal(raw, re); err != nil {
+			return err
+		}
+
+		if re.ErrorType == "" {
+			// before WebDriver error codes, errors had a JSON Wire Protocol status
+			var legacy struct {
+				Status interface{} `json:"status"`
+			}
+
+			json.Unmarshal(raw, &legacy)
+			switch status := legacy.Status.(type) {
+			case string:
+				re.ErrorType = status
+			case float64:
+				re.ErrorType = legacyStatus[int(status)]
+			}
+		}
+
+		if re.ErrorType == "" {
+			re.ErrorType = "unknown error"
+		}
+
+		return re
+	}
+
+	// the rest of the object is the result, as with protocol 3. Results that
+	// aren't objects, like the lists of elements or window handles, are sent
+	// in value, and are returned bare as protocol 3 does.
+	delete(v, "from")
+	delete(v, "error")
+	if raw, found := v["value"]; found && len(v) == 1 && bytes.HasPrefix(bytes.TrimSpace(raw), []byte("[")) {
+		r.Value = string(raw)
+		return nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	r.Value = string(b)
+	return nil
+}
+
+// legacyStatus maps JSON Wire Protocol status codes to WebDriver error codes.
+var legacyStatus = map[int]string{
+	6:  "invalid session id",
+	7:  "no such element",
+	8:  "no such frame",
+	9:  "unknown command",
+	10: "stale element reference",
+	11: "element not visible",
+	12: "invalid element state",
+	13: "unknown error",
+	15: "element not selectable",
+	17: "javascript error",
+	19: "invalid xpath selector",
+	21: "timeout",
+	23: "no such window",
+	24: "invalid cookie domain",
+	25: "unable to set cookie",
+	26: "unexpected alert open",
+	27: "no such alert",
+	28: "script timeout",
+	32: "invalid selector",
+}
@@ -0,0 +1,172 @@
+package marionette_client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func TestEncodeGolden(t *testing.T) {
+	for _, tc := range []struct {
+		version int32
+		command string
+		values  interface{}
+		frame   string
+	}{
+		{3, "getTitle", nil, `21:[0,7,"getTitle",null]`},
+		{3, "get", map[string]string{"url": "about:blank"}, `33:[0,7,"get",{"url":"about:blank"}]`},
+		{2, "getTitle", nil, `37:{"name":"getTitle","parameters":null}`},
+		{2, "get", map[string]string{"url": "about:blank"}, `49:{"name":"get","parameters":{"url":"about:blank"}}`},
+	} {
+		de, err := NewDecoderEncoder(tc.version)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := de.Encode(&MarionetteTransport{messageID: 7}, tc.command, tc.values)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(b) != tc.frame {
+			t.Errorf("protocol %v: expected %s, got %s", tc.version, tc.frame, b)
+		}
+	}
+
+	if _, err := NewDecoderEncoder(1); err == nil {
+		t.Fatal("expected protocol 1 to be refused")
+	}
+}
+
+func TestDecodeGolden(t *testing.T) {
+	for _, tc := range []struct {
+		version int32
+		frame   string
+		id      int32
+		value   string
+		err     error
+	}{
+		{3, `[1,7,null,{"value":"Example Domain"}]`, 7, `{"value":"Example Domain"}`, nil},
+		{3, `[1,8,{"error":"no such element","message":"m","stacktrace":null},null]`, 8, "", ErrNoSuchElement},
+		{2, `{"from":"root","value":"Example Domain"}`, 0, `{"value":"Example Domain"}`, nil},
+		{2, `{"from":"root","sessionId":"s","value":{"browserName":"firefox"}}`, 0, `{"sessionId":"s","value":{"browserName":"firefox"}}`, nil},
+		{2, `{"from":"root","error":{"error":"no such element","message":"m","stacktrace":""}}`, 0, "", ErrNoSuchElement},
+		{2, `{"from":"root","error":{"message":"m","status":10}}`, 0, "", ErrStaleElementReference},
+		{2, `{"from":"root","error":{"message":"m","status":"no such frame"}}`, 0, "", ErrNoSuchFrame},
+	} {
+		de, _ := NewDecoderEncoder(tc.version)
+		r := &Response{}
+		err := de.Decode([]byte(tc.frame), r)
+		if tc.err != nil {
+			if !errors.Is(err, tc.err) {
+				t.Errorf("protocol %v %s: expected %v, got %v", tc.version, tc.frame, tc.err, err)
+			}
+
+			continue
+		}
+
+		if err != nil || r.MessageID != tc.id || r.Value != tc.value {
+			t.Errorf("protocol %v %s: expected %v %s, got %v %s %v", tc.version, tc.frame, tc.id, tc.value, r.MessageID, r.Value, err)
+		}
+	}
+
+	if err := (ProtoV2DecoderEncoder{}).Decode([]byte(`[1,2]`), &Response{}); err == nil {
+		t.Fatal("expected a malformed message error")
+	}
+}
+
+func connectV2(t *testing.T) (*Client, *marionettetest.Server) {
+	s := marionettetest.NewUnstartedServer()
+	s.Protocol = 2
+	s.Start()
+	c := NewClient()
+	if err := c.Connect(s.Host(), s.Port()); err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		c.transport.Close()
+		s.Close()
+	})
+
+	return c, s
+}
+
+func TestProtocolV2Fake(t *testing.T) {
+	c, s := connectV2(t)
+	s.HandleValue("getTitle", "Example Domain")
+	s.HandleError("findElement", "no such element", "Unable to locate element")
+
+	if _, err := c.NewSession("", nil); err != nil || c.SessionID() != "00000000-0000-0000-0000-000000000000" {
+		t.Fatalf("new session failed: %v %q", err, c.SessionID())
+	}
+
+	title, err := c.Title()
+	if err != nil || title != "Example Domain" {
+		t.Fatalf("got %q, %v", title, err)
+	}
+
+	if _, err := c.FindElement(By(ID), "missing"); !errors.Is(err, ErrNoSuchElement) {
+		t.Fatalf("expected no such element, got %v", err)
+	}
+
+	// lists are results that aren't objects, sent in value.
+	s.Handle("findElements", func(marionettetest.Command) (interface{}, error) {
+		return []interface{}{marionettetest.Element("e1"), marionettetest.Element("e2")}, nil
+	})
+	s.Handle("getWindowHandles", func(marionettetest.Command) (interface{}, error) {
+		return []string{"w1", "w2"}, nil
+	})
+
+	elements, err := c.FindElements(By(CSS_SELECTOR), "a")
+	if err != nil || len(elements) != 2 || elements[1].Id() != "e2" {
+		t.Fatalf("got %v, %v", elements, err)
+	}
+
+	handles, err := c.WindowHandles()
+	if err != nil || !reflect.DeepEqual(handles, []string{"w1", "w2"}) {
+		t.Fatalf("got %v, %v", handles, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if title, err := c.Title(); err != nil || title != "Example Domain" {
+				t.Errorf("got %q, %v", title, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestProtocolV2Cancel(t *testing.T) {
+	c, s := connectV2(t)
+	s.HandleValue("getTitle", "Example Domain")
+	s.Handle("get", func(marionettetest.Command) (interface{}, error) {
+		time.Sleep(100 * time.Millisecond)
+		return marionettetest.Value("navigated"), nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	var ce *CancelledError
+	if _, err := c.BindContext(ctx).Navigate("http://example.com/"); !errors.As(err, &ce) {
+		t.Fatalf("expected a *CancelledError, got %v", err)
+	}
+
+	// the late response of get must not be taken for the title.
+	title, err := c.Title()
+	if err != nil || title != "Example Domain" {
+		t.Fatalf("got %q, %v", title, err)
+	}
+}
@@ -0,0 +1,135 @@
+package marionette_client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/png"
+	"os"
+)
+
+// ScreenshotOptions select what a screenshot captures.
+type ScreenshotOptions struct {
+	// Full captures the whole scrollable document instead of the viewport.
+	// Element screenshots ignore it.
+	Full bool
+
+	// Highlights are outlined in the capture.
+	Highlights []*WebElement
+
+	// HideScrollbars hides the scrollbars of the document during the
+	// capture.
+	HideScrollbars bool
+}
+
+// hideScrollbarsScript hides the scrollbars of the document, returning the
+// previous value of its scrollbar-width style, which restoreScrollbarsScript
+// sets back.
+const (
+	hideScrollbarsScript = `let s = document.documentElement.style;
+let prev = s.scrollbarWidth;
+s.scrollbarWidth = "none";
+return prev;`
+	restoreScrollbarsScript = `document.documentElement.style.scrollbarWidth = arguments[0];`
+)
+
+// screenshotPNG takes a screenshot of the element id, or of the document if
+// id is empty, and returns the decoded PNG.
+func screenshotPNG(c *Client, id string, opts *ScreenshotOptions) ([]byte, error) {
+	if opts == nil {
+		opts = &ScreenshotOptions{}
+	}
+
+	params := map[string]interface{}{"full": opts.Full}
+	if id != "" {
+		params["id"] = id
+	}
+
+	if len(opts.Highlights) > 0 {
+		highlights := make([]string, len(opts.Highlights))
+		for i, e := range opts.Highlights {
+			highlights[i] = e.Id()
+		}
+
+		params["highlights"] = highlights
+	}
+
+	if opts.HideScrollbars {
+		var prev string
+		if err := c.ExecuteScriptInto(&prev, hideScrollbarsScript); err != nil {
+			return nil, err
+		}
+
+		defer c.ExecuteScriptInto(nil, restoreScrollbarsScript, prev)
+	}
+
+	r, err := c.send("takeScreenshot", params)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := stringValue(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(s)
+}
+
+func screenshotImage(c *Client, id string, opts *ScreenshotOptions) (image.Image, error) {
+	b, err := screenshotPNG(c, id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return png.Decode(bytes.NewReader(b))
+}
+
+func screenshotToFile(c *Client, id string, name string, opts *ScreenshotOptions) error {
+	b, err := screenshotPNG(c, id, opts)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(name, b, 0644)
+}
+
+// ScreenshotPNG returns a PNG screenshot of the document.
+func (c *Client) ScreenshotPNG(opts *ScreenshotOptions) ([]byte, error) {
+	return screenshotPNG(c, "", opts)
+}
+
+// ScreenshotImage returns a screenshot of the document.
+func (c *Client) ScreenshotImage(opts *ScreenshotOptions) (image.Image, error) {
+	return screenshotImage(c, "", opts)
+}
+
+// ScreenshotToFile writes a PNG screenshot of the document to the file name.
+func (c *Client) ScreenshotToFile(name string, opts *ScreenshotOptions) error {
+	return screenshotToFile(c, "", name, opts)
+}
+
+// ScreenshotPNG returns a PNG screenshot of the element.
+func (e *WebElement) ScreenshotPNG(opts *ScreenshotOptions) (b []byte, err error) {
+	err = e.do(func(id string) error {
+		b, err = screenshotPNG(e.c, id, opts)
+		return err
+	})
+
+	return b, err
+}
+
+// ScreenshotImage returns a screenshot of the element.
+func (e *WebElement) ScreenshotImage(opts *ScreenshotOptions) (img image.Image, err error) {
+	err = e.do(func(id string) error {
+		img, err = screenshotImage(e.c, id, opts)
+		return err
+	})
+
+	return img, err
+}
+
+// ScreenshotToFile writes a PNG screenshot of the element to the file name.
+func (e *WebElement) ScreenshotToFile(name string, opts *ScreenshotOptions) error {
+	return e.do(func(id string) error { return screenshotToFile(e.c, id, name, opts) })
+}
@@ -0,0 +1,89 @@
+package marionette_client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScreenshotFake(t *testing.T) {
+	c, s := connect(t)
+
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(1, 0, color.RGBA{255, 0, 0, 255})
+	var b bytes.Buffer
+	png.Encode(&b, img)
+	s.HandleValue("takeScreenshot", base64.StdEncoding.EncodeToString(b.Bytes()))
+
+	got, err := c.ScreenshotPNG(nil)
+	if err != nil || !bytes.Equal(got, b.Bytes()) {
+		t.Fatalf("unexpected PNG %v", err)
+	}
+
+	e := c.ElementFromID("e1")
+	decoded, err := e.ScreenshotImage(&ScreenshotOptions{Highlights: []*WebElement{c.ElementFromID("e2")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Bounds().Dx() != 2 || color.RGBAModel.Convert(decoded.At(1, 0)) != (color.RGBA{255, 0, 0, 255}) {
+		t.Fatalf("unexpected image %v", decoded.Bounds())
+	}
+
+	name := filepath.Join(t.TempDir(), "page.png")
+	if err := c.ScreenshotToFile(name, &ScreenshotOptions{Full: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if written, err := os.ReadFile(name); err != nil || !bytes.Equal(written, b.Bytes()) {
+		t.Fatalf("unexpected file content %v", err)
+	}
+
+	cmds := s.Received("takeScreenshot")
+	params := make([]struct {
+		ID         string
+		Full       bool
+		Highlights []string
+	}, len(cmds))
+	for i, cmd := range cmds {
+		cmd.Decode(&params[i])
+	}
+
+	if params[0].Full || params[0].ID != "" ||
+		params[1].ID != "e1" || len(params[1].Highlights) != 1 || params[1].Highlights[0] != "e2" ||
+		!params[2].Full {
+		t.Fatalf("unexpected parameters %+v", params)
+	}
+}
+
+func TestScreenshotHideScrollbarsFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("executeScript", "auto")
+	s.HandleValue("takeScreenshot", "")
+
+	if _, err := c.ScreenshotPNG(&ScreenshotOptions{HideScrollbars: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, cmd := range s.Commands() {
+		names = append(names, cmd.Name)
+	}
+
+	if len(names) != 3 || names[0] != "executeScript" || names[1] != "takeScreenshot" || names[2] != "executeScript" {
+		t.Fatalf("unexpected commands %v", names)
+	}
+
+	var restore struct {
+		Args []string
+	}
+	s.Received("executeScript")[1].Decode(&restore)
+	if len(restore.Args) != 1 || restore.Args[0] != "auto" {
+		t.Fatalf("expected the previous scrollbar width to be restored, got %v", restore.Args)
+	}
+}
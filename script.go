@@ -0,0 +1,111 @@
+package marionette_client
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// executeScriptInto sends the script command, executeScript or
+// executeAsyncScript, and decodes the value of the response into v.
+func executeScriptInto(c *Client, command string, v interface{}, script string, args []interface{}) error {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	r, err := c.send(command, map[string]interface{}{"script": script, "args": args})
+	if err != nil {
+		return err
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	var d struct {
+		Value json.RawMessage `json:"value"`
+	}
+
+	if err := json.Unmarshal([]byte(r.Value), &d); err != nil {
+		return err
+	}
+
+	if len(d.Value) == 0 {
+		d.Value = json.RawMessage("null")
+	}
+
+	if err := json.Unmarshal(d.Value, v); err != nil {
+		return err
+	}
+
+	bindElements(c, reflect.ValueOf(v))
+	return nil
+}
+
+var webElementType = reflect.TypeOf(WebElement{})
+
+// bindElements binds the web elements reachable from v to c, and replaces
+// the web element references decoded into interface{} values by *WebElement.
+func bindElements(c *Client, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			bindElements(c, v.Elem())
+		}
+	case reflect.Interface:
+		if !v.IsNil() && v.CanSet() {
+			if e := elements(c, v.Elem().Interface()); e != nil {
+				v.Set(reflect.ValueOf(e))
+			}
+		}
+	case reflect.Struct:
+		if v.Type() == webElementType {
+			if v.CanAddr() {
+				v.Addr().Interface().(*WebElement).c = c
+			}
+
+			return
+		}
+
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" { // exported
+				bindElements(c, v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			bindElements(c, v.Index(i))
+		}
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			e := reflect.New(v.Type().Elem()).Elem()
+			e.Set(v.MapIndex(k))
+			bindElements(c, e)
+			v.SetMapIndex(k, e)
+		}
+	}
+}
+
+// elements returns x, as decoded into an interface{}, with its web element
+// references replaced by *WebElement.
+func elements(c *Client, x interface{}) interface{} {
+	switch x := x.(type) {
+	case map[string]interface{}:
+		if id, ok := x[WEBDRIVER_ELEMENT_KEY].(string); ok {
+			return &WebElement{id: id, c: c}
+		}
+
+		if id, ok := x[LEGACY_ELEMENT_KEY].(string); ok {
+			return &WebElement{id: id, c: c}
+		}
+
+		for k, v := range x {
+			x[k] = elements(c, v)
+		}
+	case []interface{}:
+		for i, v := range x {
+			x[i] = elements(c, v)
+		}
+	}
+
+	return x
+}
@@ -0,0 +1,133 @@
+package marionette_client
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func TestExecuteScriptIntoFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("getElementText", "text")
+	s.HandleValue("executeScript", map[string]interface{}{
+		"link":   marionettetest.Element("e1"),
+		"items":  []interface{}{marionettetest.Element("e2"), marionettetest.Element("e3")},
+		"count":  2,
+		"nested": map[string]interface{}{"a": marionettetest.Element("e4")},
+	})
+
+	var result struct {
+		Link   *WebElement            `json:"link"`
+		Items  []*WebElement          `json:"items"`
+		Count  int                    `json:"count"`
+		Nested map[string]*WebElement `json:"nested"`
+	}
+
+	arg := c.ElementFromID("e0")
+	if err := c.ExecuteScriptInto(&result, "return f(arguments[0], arguments[1]);", arg, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	var p struct {
+		Script string
+		Args   []json.RawMessage
+	}
+	s.Received("executeScript")[0].Decode(&p)
+	if len(p.Args) != 2 || string(p.Args[0]) != `{"`+WEBDRIVER_ELEMENT_KEY+`":"e0"}` || string(p.Args[1]) != "2" {
+		t.Fatalf("unexpected arguments %s", p.Args)
+	}
+
+	if result.Link.Id() != "e1" || len(result.Items) != 2 || result.Items[1].Id() != "e3" || result.Count != 2 || result.Nested["a"].Id() != "e4" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	for _, e := range []*WebElement{result.Link, result.Items[0], result.Nested["a"]} {
+		if text, err := e.Text(); err != nil || text != "text" {
+			t.Fatalf("element %v is not bound to the client: %q %v", e.Id(), text, err)
+		}
+	}
+}
+
+func TestExecuteScriptIntoInterfaceFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("executeScript", []interface{}{
+		marionettetest.Element("e1"),
+		map[string]interface{}{"el": marionettetest.Element("e2"), "n": 1},
+		map[string]interface{}{"ELEMENT": "e3", marionettetest.ElementKey: "e3"}, // older Marionette
+		map[string]interface{}{"ELEMENT": "e4"},
+	})
+
+	var v interface{}
+	if err := c.ExecuteScriptInto(&v, "return [];"); err != nil {
+		t.Fatal(err)
+	}
+
+	a := v.([]interface{})
+	if e, ok := a[0].(*WebElement); !ok || e.Id() != "e1" || e.c != c {
+		t.Fatalf("expected a bound *WebElement, got %#v", a[0])
+	}
+
+	if e, ok := a[1].(map[string]interface{})["el"].(*WebElement); !ok || e.Id() != "e2" {
+		t.Fatalf("expected a nested *WebElement, got %#v", a[1])
+	}
+
+	for i, id := range []string{"e3", "e4"} {
+		if e, ok := a[i+2].(*WebElement); !ok || e.Id() != id {
+			t.Fatalf("expected a legacy reference to be a *WebElement, got %#v", a[i+2])
+		}
+	}
+
+	s.HandleValue("executeScript", map[string]string{"ELEMENT": "e5"})
+	var e *WebElement
+	if err := c.ExecuteScriptInto(&e, "return document.body;"); err != nil || e.Id() != "e5" {
+		t.Fatalf("expected the legacy reference e5, got %#v, %v", e, err)
+	}
+
+	s.HandleValue("executeScript", nil)
+	n := 42
+	if err := c.ExecuteScriptInto(&n, "return null;"); err != nil || n != 42 {
+		t.Fatalf("expected null to leave the value untouched, got %v %v", n, err)
+	}
+
+	if err := c.ExecuteScriptInto(nil, "return 1;"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExecuteAsyncScriptFake(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("executeAsyncScript", 200)
+
+	var status int
+	if err := c.ExecuteAsyncScriptInto(&status, "fetch(arguments[0]).then(r => arguments[1](r.status));", "/"); err != nil || status != 200 {
+		t.Fatalf("got %v, %v", status, err)
+	}
+
+	r, err := c.ExecuteAsyncScript("arguments[0](1);", nil, 500, false)
+	if err != nil || r.Value != `{"value":200}` {
+		t.Fatalf("got %v, %v", r, err)
+	}
+
+	var p struct {
+		Args          []interface{}
+		ScriptTimeout int
+	}
+	s.Received("executeAsyncScript")[1].Decode(&p)
+	if p.Args == nil || len(p.Args) != 0 || p.ScriptTimeout != 500 {
+		t.Fatalf("unexpected parameters %+v", p)
+	}
+
+	s.HandleError("executeAsyncScript", "script timeout", "Timed out after 500 ms")
+	err = c.ExecuteAsyncScriptInto(nil, "")
+	if !errors.Is(err, ErrScriptTimeout) || errors.Is(err, ErrJavaScript) {
+		t.Fatalf("expected a script timeout, got %v", err)
+	}
+
+	s.HandleError("executeAsyncScript", "javascript error", "ReferenceError: f is not defined")
+	err = c.ExecuteAsyncScriptInto(nil, "f();")
+	if !errors.Is(err, ErrJavaScript) || errors.Is(err, ErrScriptTimeout) {
+		t.Fatalf("expected a javascript error, got %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package marionette_client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// locator is how an element was found: by and value, within parent or the
+// document, and its index in the result of findElements, -1 for findElement.
+// It is shared by the BindContext copies of the element, and holds the
+// reference the element was located again with.
+type locator struct {
+	by     By
+	value  string
+	parent *WebElement
+	index  int
+
+	mu sync.Mutex
+	id string
+}
+
+func (l *locator) current() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.id
+}
+
+// byIndex reports whether the element, or one of its parents, was found by
+// its position in a list.
+func (l *locator) byIndex() bool {
+	for l != nil {
+		if l.index >= 0 {
+			return true
+		}
+
+		if l.parent == nil {
+			return false
+		}
+
+		l = l.parent.loc
+	}
+
+	return false
+}
+
+// SetStaleElementRetry turns the implicit retry of stale elements on or off,
+// for the client, its BindContext copies and their elements. When on, a
+// command on an element failing with a stale element reference, e.g. after a
+// navigation or a re-render of the DOM, locates the element again with the
+// locator it was found with, within its parent located again too if needed,
+// and is sent once more.
+//
+// Only elements returned by FindElement and FindElements are located again;
+// elements of script results or ElementFromID aren't. An element of
+// FindElements is located again by its position in the list, which may now
+// hold another element: Click, SendKeys and Clear aren't retried on such
+// elements, or on the elements found within them.
+func (c *Client) SetStaleElementRetry(enabled bool) {
+	c.session.mu.Lock()
+	c.session.retryStale = enabled
+	c.session.mu.Unlock()
+}
+
+// OnElementRelocated sets fn to be called every time a stale element is
+// located again, with the element, its stale reference and the error it
+// couldn't be located with, if any. A nil fn removes the hook.
+func (c *Client) OnElementRelocated(fn func(e *WebElement, staleID string, err error)) {
+	c.session.mu.Lock()
+	c.session.relocated = fn
+	c.session.mu.Unlock()
+}
+
+func (c *Client) staleRetry() (bool, func(e *WebElement, staleID string, err error)) {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+
+	return c.session.retryStale, c.session.relocated
+}
+
+// do runs fn with the current reference of e. If fn fails with a stale element
+// reference and stale element retry is on, e is located again and fn runs a
+// second time.
+func (e *WebElement) do(fn func(id string) error) error {
+	return e.retry(false, fn)
+}
+
+// change is do for commands changing the state of the element, which are not
+// retried on an element that may be located again as another one.
+func (e *WebElement) change(fn func(id string) error) error {
+	return e.retry(true, fn)
+}
+
+func (e *WebElement) retry(changes bool, fn func(id string) error) error {
+	id := e.Id()
+	err := fn(id)
+	if e.loc == nil || !errors.Is(err, ErrStaleElementReference) {
+		return err
+	}
+
+	retry, hook := e.c.staleRetry()
+	if !retry || changes && e.loc.byIndex() {
+		return err
+	}
+
+	rerr := e.relocate()
+	if hook != nil {
+		hook(e, id, rerr)
+	}
+
+	if rerr != nil {
+		return err
+	}
+
+	return fn(e.Id())
+}
+
+// relocate looks e up again with its locator, and updates its reference.
+func (e *WebElement) relocate() error {
+	var f Finder = e.c
+	if e.loc.parent != nil {
+		f = e.loc.parent
+	}
+
+	var found *WebElement
+	if e.loc.index < 0 {
+		var err error
+		if found, err = f.FindElement(e.loc.by, e.loc.value); err != nil {
+			return err
+		}
+	} else {
+		all, err := f.FindElements(e.loc.by, e.loc.value)
+		if err != nil {
+			return err
+		}
+
+		if e.loc.index >= len(all) {
+			return &DriverError{
+				ErrorType: ErrNoSuchElement.Error(),
+				Message:   fmt.Sprintf("element %d of %v %q no longer exists", e.loc.index, e.loc.by, e.loc.value),
+			}
+		}
+
+		found = all[e.loc.index]
+	}
+
+	e.loc.mu.Lock()
+	e.loc.id = found.Id()
+	e.loc.mu.Unlock()
+
+	return nil
+}
@@ -0,0 +1,202 @@
+package marionette_client
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+// staleServer answers findElement with e1, then e2, and reports e1 stale.
+func staleServer(t *testing.T) (*Client, *marionettetest.Server) {
+	c, s := connect(t)
+	var finds int32
+	s.Handle("findElement", func(cmd marionettetest.Command) (interface{}, error) {
+		if atomic.AddInt32(&finds, 1) == 1 {
+			return marionettetest.Value(marionettetest.Element("e1")), nil
+		}
+
+		return marionettetest.Value(marionettetest.Element("e2")), nil
+	})
+	s.Handle("getElementText", func(cmd marionettetest.Command) (interface{}, error) {
+		var p struct{ ID string }
+		cmd.Decode(&p)
+		if p.ID == "e1" {
+			return nil, &marionettetest.Error{Type: "stale element reference"}
+		}
+
+		return marionettetest.Value("text of " + p.ID), nil
+	})
+
+	return c, s
+}
+
+func TestStaleElementRetryFake(t *testing.T) {
+	c, _ := staleServer(t)
+	c.SetStaleElementRetry(true)
+
+	var relocated []string
+	c.OnElementRelocated(func(e *WebElement, staleID string, err error) {
+		relocated = append(relocated, staleID+">"+e.Id())
+		if err != nil {
+			t.Errorf("unexpected relocation error %v", err)
+		}
+	})
+
+	e, err := c.FindElement(By(ID), "q")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text, err := e.Text()
+	if err != nil || text != "text of e2" {
+		t.Fatalf("expected the text of the relocated element, got %q, %v", text, err)
+	}
+
+	if e.Id() != "e2" || len(relocated) != 1 || relocated[0] != "e1>e2" {
+		t.Fatalf("expected e1 relocated as e2 once, got %v, %v", e.Id(), relocated)
+	}
+}
+
+func TestStaleElementRetryOffFake(t *testing.T) {
+	c, s := staleServer(t)
+
+	e, err := c.FindElement(By(ID), "q")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.Text(); !errors.Is(err, ErrStaleElementReference) {
+		t.Fatalf("expected a stale element reference, got %#v", err)
+	}
+
+	if n := len(s.Received("findElement")); n != 1 {
+		t.Fatalf("expected no relocation, got %v lookups", n)
+	}
+
+	c.SetStaleElementRetry(true)
+	if _, err := c.ElementFromID("e1").Text(); !errors.Is(err, ErrStaleElementReference) {
+		t.Fatalf("expected elements without locator not to be retried, got %#v", err)
+	}
+}
+
+func TestStaleElementRetryChainFake(t *testing.T) {
+	c, s := staleServer(t)
+	c.SetStaleElementRetry(true)
+
+	// items of the stale form e1 are located again within e2
+	s.Handle("findElements", func(cmd marionettetest.Command) (interface{}, error) {
+		var p struct{ Element string }
+		cmd.Decode(&p)
+		if p.Element == "e1" {
+			return []interface{}{marionettetest.Element("i1"), marionettetest.Element("i2")}, nil
+		}
+
+		return []interface{}{marionettetest.Element("j1"), marionettetest.Element("j2")}, nil
+	})
+	s.Handle("isElementDisplayed", func(cmd marionettetest.Command) (interface{}, error) {
+		var p struct{ ID string }
+		cmd.Decode(&p)
+		if p.ID == "i2" {
+			return nil, &marionettetest.Error{Type: "stale element reference"}
+		}
+
+		return marionettetest.Value(true), nil
+	})
+
+	form, err := c.FindElement(By(ID), "form")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := form.FindElements(By(TAG_NAME), "li")
+	if err != nil || len(items) != 2 {
+		t.Fatalf("expected 2 items, got %v, %v", items, err)
+	}
+
+	// the item is stale, so is its parent: both are located again
+	s.Handle("findElements", func(cmd marionettetest.Command) (interface{}, error) {
+		var p struct{ Element string }
+		cmd.Decode(&p)
+		if p.Element == "e1" {
+			return nil, &marionettetest.Error{Type: "stale element reference"}
+		}
+
+		return []interface{}{marionettetest.Element("j1"), marionettetest.Element("j2")}, nil
+	})
+
+	displayed, err := items[1].Displayed()
+	if err != nil || !displayed || items[1].Id() != "j2" || form.Id() != "e2" {
+		t.Fatalf("expected the item relocated within the form relocated, got %v, %v, %v, %v", displayed, err, items[1].Id(), form.Id())
+	}
+}
+
+func TestStaleElementRetryIndexFake(t *testing.T) {
+	c, s := connect(t)
+	c.SetStaleElementRetry(true)
+	s.Handle("findElements", func(cmd marionettetest.Command) (interface{}, error) {
+		return []interface{}{marionettetest.Element("i1"), marionettetest.Element("i2")}, nil
+	})
+	s.HandleError("clickElement", "stale element reference", "")
+	s.HandleError("getElementText", "stale element reference", "")
+
+	relocations := 0
+	c.OnElementRelocated(func(e *WebElement, staleID string, err error) { relocations++ })
+
+	items, err := c.FindElements(By(TAG_NAME), "li")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the list may have changed: clicking the second element found again
+	// could click another one
+	if err := items[1].Click(); !errors.Is(err, ErrStaleElementReference) || relocations != 0 {
+		t.Fatalf("expected the click not to be retried, got %v after %v relocations", err, relocations)
+	}
+
+	if _, err := items[1].Text(); !errors.Is(err, ErrStaleElementReference) || relocations != 1 {
+		t.Fatalf("expected the text to be read again, got %v after %v relocations", err, relocations)
+	}
+}
+
+func TestStaleElementRetryBindContextFake(t *testing.T) {
+	c, _ := staleServer(t)
+	c.SetStaleElementRetry(true)
+
+	e, err := c.FindElement(By(ID), "q")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bound := e.BindContext(context.Background())
+	if _, err := bound.Text(); err != nil {
+		t.Fatal(err)
+	}
+
+	if bound.Id() != "e2" || e.Id() != "e2" {
+		t.Fatalf("expected both copies to refer to e2, got %v and %v", bound.Id(), e.Id())
+	}
+}
+
+func TestElementIsStaleRetryFake(t *testing.T) {
+	c, s := staleServer(t)
+	s.Handle("getElementTagName", func(cmd marionettetest.Command) (interface{}, error) {
+		return nil, &marionettetest.Error{Type: "stale element reference"}
+	})
+	c.SetStaleElementRetry(true)
+
+	e, err := c.FindElement(By(ID), "q")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _, err := ElementIsStale(e)(c); !ok || err != nil {
+		t.Fatalf("expected e1 to be stale, got %v, %v", ok, err)
+	}
+
+	if e.Id() != "e1" || len(s.Received("findElement")) != 1 {
+		t.Fatalf("expected e1 not to be located again, got %v", e.Id())
+	}
+}
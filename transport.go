@@ -1,29 +1,81 @@
 package marionette_client
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// default read and write time out of a single command, used when the context
+// carries no earlier deadline.
+var defaultTimeout = time.Minute * 5
+
 type Transporter interface {
 	MessageID() int
 	Connect(host string, port int) error
+	ConnectContext(ctx context.Context, host string, port int) error
 	Close() error
 	Send(command string, values interface{}) (*Response, error)
+	SendContext(ctx context.Context, command string, values interface{}) (*Response, error)
 	Receive() ([]byte, error)
 }
 
+// CancelledError is returned when a command, or a wait, is aborted because its
+// context was cancelled or its deadline expired before Marionette answered.
+// Err is context.Canceled or context.DeadlineExceeded, so errors.Is works
+// against both.
+type CancelledError struct {
+	Command string
+	Err     error
+}
+
+func (e *CancelledError) Error() string {
+	if e.Command == "" {
+		return "marionette: aborted: " + e.Err.Error()
+	}
+
+	return fmt.Sprintf("marionette: %v aborted: %v", e.Command, e.Err)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Err
+}
+
+// ErrClosed is returned for commands outstanding, or sent, after the
+// transport was closed.
+var ErrClosed = errors.New("Connection closed.")
+
+// MarionetteTransport is safe for concurrent use. Commands are written under a
+// lock and a single reader goroutine hands every response to the caller
+// waiting on its message ID, so many commands can be outstanding at once.
 type MarionetteTransport struct {
 	ApplicationType    string
 	MarionetteProtocol int32
-	messageID          int
-	conn               net.Conn
-	de                 DecoderEncoder
+	messageID          int64 // accessed atomically
+
+	writeMu sync.Mutex // serializes message IDs and frames on the wire
+
+	mu      sync.Mutex // guards the fields below, and the handshake fields above
+	de      DecoderEncoder
+	conn    net.Conn
+	r       *bufio.Reader
+	pending map[int32]chan result
+	err     error         // why the reader stopped, if it did
+	done    chan struct{} // closed when the reader stops
+}
+
+type result struct {
+	r   *Response
+	err error
 }
 
 type Response struct {
@@ -34,11 +86,20 @@ type Response struct {
 }
 
 func (t *MarionetteTransport) MessageID() int {
-	return t.messageID
+	return int(atomic.LoadInt64(&t.messageID))
 }
 
 func (t *MarionetteTransport) Connect(host string, port int) error {
-	if t.conn != nil {
+	return t.ConnectContext(context.Background(), host, port)
+}
+
+// ConnectContext dials Marionette and reads the handshake. Cancelling ctx, or
+// reaching its deadline, aborts both the dial and the handshake.
+func (t *MarionetteTransport) ConnectContext(ctx context.Context, host string, port int) error {
+	t.mu.Lock()
+	connected := t.conn != nil
+	t.mu.Unlock()
+	if connected {
 		return errors.New("A Connection is already established. please disconnect before connecting.")
 	}
 
@@ -51,60 +112,132 @@ func (t *MarionetteTransport) Connect(host string, port int) error {
 	}
 
 	hostname := host + ":" + strconv.Itoa(port)
-	c, err := net.Dial("tcp", hostname)
+	var dialer net.Dialer
+	c, err := dialer.DialContext(ctx, "tcp", hostname)
 	if err != nil {
+		if ctx.Err() != nil {
+			return &CancelledError{"connect", ctx.Err()}
+		}
+
 		return err
 	}
 
-	t.conn = c
-	t.conn.SetDeadline(time.Now().Add(time.Minute * 5)) // default read and write time out
-	r, err := t.Receive()
+	br := bufio.NewReader(c)
+	stop := watch(ctx, c.SetDeadline)
+	r, err := read(br)
+	stop()
 	if err != nil {
-		return err
+		c.Close()
+		return contextError(ctx, "connect", err)
+	}
+
+	var handshake struct {
+		ApplicationType    string
+		MarionetteProtocol int32
 	}
 
-	err = json.Unmarshal([]byte(r), &t)
+	err = json.Unmarshal([]byte(r), &handshake)
 	if err != nil {
+		c.Close()
 		return err
 	}
 
-	d, err := NewDecoderEncoder(t.MarionetteProtocol)
+	d, err := NewDecoderEncoder(handshake.MarionetteProtocol)
 	if err != nil {
+		c.Close()
 		return err
 	}
 
+	done := make(chan struct{})
+	t.mu.Lock()
+	t.ApplicationType = handshake.ApplicationType
+	t.MarionetteProtocol = handshake.MarionetteProtocol
 	t.de = d
+	t.conn = c
+	t.r = br
+	t.pending = make(map[int32]chan result)
+	t.err = nil
+	t.done = done
+	t.mu.Unlock()
+
+	go t.readLoop(br, d, done)
 
 	return nil
 }
 
+// Close closes the connection. Commands still waiting for a response fail with
+// ErrClosed.
 func (t *MarionetteTransport) Close() error {
-	err := t.conn.Close()
-	if err != nil {
-		return err
+	t.mu.Lock()
+	c, done := t.conn, t.done
+	t.conn = nil
+	t.mu.Unlock()
+	if c == nil {
+		return errors.New("Not connected.")
+	}
+
+	t.fail(ErrClosed)
+	err := c.Close()
+	<-done
+	if errors.Is(err, net.ErrClosed) {
+		return nil
 	}
 
-	t.conn = nil
 	return err
 }
 
 func (t *MarionetteTransport) Send(command string, values interface{}) (*Response, error) {
-	t.messageID = t.messageID + 1 // next message ID
-	buf, err := t.de.Encode(t, command, values)
-	if err != nil {
-		return nil, err
+	return t.SendContext(context.Background(), command, values)
+}
+
+// SendContext sends the command and waits for its response. Cancelling ctx, or
+// reaching its deadline, returns a *CancelledError right away; the response of
+// the abandoned command is discarded when it arrives. A ctx without a deadline
+// times out after 5 minutes, with a *CancelledError matching
+// context.DeadlineExceeded. It is safe to call
+// SendContext from several goroutines at once.
+func (t *MarionetteTransport) SendContext(ctx context.Context, command string, values interface{}) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, &CancelledError{command, err}
+	}
+
+	t.mu.Lock()
+	de := t.de
+	t.mu.Unlock()
+	if de == nil {
+		return nil, errors.New("Not connected. please connect before sending commands.")
 	}
 
-	_, err = write(t.conn, buf)
+	t.writeMu.Lock()
+	id := int32(atomic.AddInt64(&t.messageID, 1)) // next message ID
+	buf, err := de.Encode(t, command, values)
 	if err != nil {
+		t.writeMu.Unlock()
 		return nil, err
 	}
 
-	rBuf, err := t.Receive()
+	ch := make(chan result, 1)
+	t.mu.Lock()
+	c, err := t.conn, t.err
+	if c == nil && err == nil {
+		err = errors.New("Not connected. please connect before sending commands.")
+	}
+
+	if err == nil {
+		t.pending[id] = ch
+	}
+	t.mu.Unlock()
+
 	if err != nil {
+		t.writeMu.Unlock()
 		return nil, err
 	}
 
+	stop := watch(ctx, c.SetWriteDeadline)
+	_, err = write(c, buf)
+	stop()
+	t.writeMu.Unlock()
+
 	//Debug only
 	if RunningInDebugMode {
 		if len(buf) >= 512 {
@@ -115,21 +248,186 @@ func (t *MarionetteTransport) Send(command string, values interface{}) (*Respons
 	}
 	//Debug only end
 
-	data := &Response{}
-	err = t.de.Decode(rBuf, data)
 	if err != nil {
-		return nil, err
+		// a partially written frame leaves the stream unusable
+		c.Close()
+		t.forget(id)
+		return nil, contextError(ctx, command, err)
 	}
 
-	return data, nil
-}
+	// without a deadline of its own, a command waits defaultTimeout at most.
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, defaultTimeout)
+		defer cancel()
+	}
 
-func write(c net.Conn, b []byte) (int, error) {
-	return c.Write(b)
+	select {
+	case res := <-ch:
+		return res.r, res.err
+	case <-ctx.Done():
+		t.abandon(id)
+		return nil, &CancelledError{command, ctx.Err()}
+	}
 }
 
+// Receive reads the next raw frame from the connection. It is used by the
+// reader goroutine, a frame read elsewhere never reaches its sender.
 func (t *MarionetteTransport) Receive() ([]byte, error) {
-	return read(t.conn)
+	t.mu.Lock()
+	r := t.r
+	t.mu.Unlock()
+	if r == nil {
+		return nil, errors.New("Not connected.")
+	}
+
+	return read(r)
+}
+
+// readLoop hands every response to the caller waiting on its message ID until
+// the connection fails or is closed.
+func (t *MarionetteTransport) readLoop(r io.Reader, de DecoderEncoder, done chan struct{}) {
+	defer close(done)
+	for {
+		buf, err := read(r)
+		if err != nil {
+			t.fail(err)
+			return
+		}
+
+		data := &Response{}
+		err = de.Decode(buf, data)
+		var de *DriverError
+		if err != nil && data.MessageID == 0 && !errors.As(err, &de) {
+			// not even the message ID could be read, the stream is unusable
+			t.fail(err)
+			return
+		}
+
+		t.mu.Lock()
+		if t.ordered() {
+			data.MessageID = t.oldest()
+		}
+
+		ch, found := t.pending[data.MessageID]
+		delete(t.pending, data.MessageID)
+		t.mu.Unlock()
+
+		if !found {
+			// answer to an abandoned command, or not an answer at all
+			if RunningInDebugMode {
+				log.Printf("discarding unexpected message %v", data.MessageID)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			ch <- result{nil, err}
+			continue
+		}
+
+		ch <- result{data, nil}
+	}
+}
+
+// fail records why the connection can't be used anymore and wakes up every
+// command waiting for a response.
+func (t *MarionetteTransport) fail(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = err
+	}
+
+	for id, ch := range t.pending {
+		ch <- result{nil, t.err}
+		delete(t.pending, id)
+	}
+}
+
+func (t *MarionetteTransport) forget(id int32) {
+	t.mu.Lock()
+	delete(t.pending, id)
+	t.mu.Unlock()
+}
+
+// abandon stops waiting for the response of the command id. With protocol 3
+// the response is discarded on arrival. Older protocols answer in order
+// without message IDs, so the command keeps its place until its response
+// arrives, instead of the response going to the next command.
+func (t *MarionetteTransport) abandon(id int32) {
+	if t.ordered() {
+		return
+	}
+
+	t.forget(id)
+}
+
+// ordered reports whether responses are matched to commands by order rather
+// than by message ID.
+func (t *MarionetteTransport) ordered() bool {
+	return t.MarionetteProtocol < MARIONETTE_PROTOCOL_V3
+}
+
+// oldest returns the message ID of the oldest command waiting, 0 if none is.
+// t.mu must be held.
+func (t *MarionetteTransport) oldest() int32 {
+	var oldest int32
+	for id := range t.pending {
+		if oldest == 0 || id < oldest {
+			oldest = id
+		}
+	}
+
+	return oldest
+}
+
+// watch applies the deadline of ctx, or the default one, through set and
+// interrupts any blocked IO as soon as ctx is done. The returned function must
+// be called once the IO is over, it clears the deadline.
+func watch(ctx context.Context, set func(time.Time) error) func() {
+	deadline := time.Now().Add(defaultTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	set(deadline)
+	done := make(chan struct{})
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			set(time.Now())
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-exited
+		set(time.Time{})
+	}
+}
+
+// contextError maps an IO error caused by ctx being done to a *CancelledError.
+func contextError(ctx context.Context, command string, err error) error {
+	if ctx.Err() != nil {
+		return &CancelledError{command, ctx.Err()}
+	}
+
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+			return &CancelledError{command, context.DeadlineExceeded}
+		}
+	}
+
+	return err
+}
+
+func write(c net.Conn, b []byte) (int, error) {
+	return c.Write(b)
 }
 
 // ReadFull reads exactly len(buf) bytes from r into buf.
@@ -138,7 +436,7 @@ func (t *MarionetteTransport) Receive() ([]byte, error) {
 // If an EOF happens after reading some but not all the bytes,
 // ReadFull returns ErrUnexpectedEOF.
 // On return, n == len(buf) if and only if err == nil.
-func read(c net.Conn) ([]byte, error) {
+func read(c io.Reader) ([]byte, error) {
 	var msgSize, err = messageLength(c)
 	if err != nil {
 		return nil, err
@@ -157,15 +455,21 @@ func read(c net.Conn) ([]byte, error) {
 // marionette's protocol.
 // the protocol say's that message length is the first part for the message until ":" is found.
 // this signals the next bytes as the message
-func messageLength(c net.Conn) (int, error) {
+func messageLength(c io.Reader) (int, error) {
 	var byteSize = make([]byte, 0)
 	tmp := make([]byte, 1)
 	for {
-		_, err := c.Read(tmp)
-		if err != nil {
-			if err != io.EOF {
-				return 0, err
+		n, err := c.Read(tmp)
+		if n == 0 {
+			if err == nil {
+				continue
 			}
+
+			if err == io.EOF && len(byteSize) > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return 0, err
 		}
 
 		if string(tmp) != ":" {
@@ -0,0 +1,247 @@
+package marionette_client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+// connect returns a client connected to a new fake server, both closed when
+// the test ends.
+func connect(t *testing.T) (*Client, *marionettetest.Server) {
+	s := marionettetest.NewServer()
+	c := NewClient()
+	if err := c.Connect(s.Host(), s.Port()); err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		c.transport.Close()
+		s.Close()
+	})
+
+	return c, s
+}
+
+// hang registers a handler that doesn't answer before the test ends.
+func hang(t *testing.T, s *marionettetest.Server, name string) {
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	s.Handle(name, func(c marionettetest.Command) (interface{}, error) {
+		<-release
+		return nil, nil
+	})
+}
+
+func TestSendContextDeadline(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("getTitle", "title")
+	hang(t, s, "get")
+
+	title, err := c.Title()
+	if err != nil || title != "title" {
+		t.Fatalf("got %q, %v", title, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = c.BindContext(ctx).Navigate("http://example.com/")
+	var ce *CancelledError
+	if !errors.As(err, &ce) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline CancelledError, got %#v", err)
+	}
+
+	if ce.Command != "get" {
+		t.Fatalf("expected command get, got %v", ce.Command)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatalf("command was not aborted at the deadline")
+	}
+}
+
+func TestSendContextCancel(t *testing.T) {
+	c, s := connect(t)
+	s.HandleValue("getTitle", "title")
+	hang(t, s, "get")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := c.BindContext(ctx).Navigate("http://example.com/")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %#v", err)
+	}
+
+	_, err = c.BindContext(ctx).Title()
+	if _, ok := err.(*CancelledError); !ok {
+		t.Fatalf("expected CancelledError for a done context, got %#v", err)
+	}
+
+	// the connection survives the abandoned command
+	title, err := c.Title()
+	if err != nil || title != "title" {
+		t.Fatalf("got %q, %v", title, err)
+	}
+}
+
+func TestConcurrentSend(t *testing.T) {
+	c, s := connect(t)
+	s.Handle("executeScript", func(cmd marionettetest.Command) (interface{}, error) {
+		var p struct{ Args []int }
+		cmd.Decode(&p)
+		time.Sleep(time.Duration(rand.Intn(5)) * time.Millisecond)
+		return marionettetest.Value(p.Args[0] * 2), nil
+	})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 20; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				n := g*100 + i
+				r, err := c.ExecuteScript("return arguments[0] * 2;", []interface{}{n}, 1000, false)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				var d map[string]int
+				json.Unmarshal([]byte(r.Value), &d)
+				if d["value"] != n*2 {
+					t.Errorf("expected %v, got %v", n*2, r.Value)
+				}
+			}
+		}(g)
+	}
+
+	wg.Wait()
+}
+
+func TestCloseFailsPending(t *testing.T) {
+	s := marionettetest.NewServer()
+	defer s.Close()
+	hang(t, s, "getTitle")
+
+	tr := &MarionetteTransport{}
+	if err := tr.Connect(s.Host(), s.Port()); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error)
+	go func() {
+		_, err := tr.Send("getTitle", nil)
+		errs <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	if err := tr.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-errs; err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %#v", err)
+	}
+
+	if _, err := tr.Send("getTitle", nil); err == nil {
+		t.Fatal("expected an error sending on a closed transport")
+	}
+}
+
+func TestSendDuringReconnectFake(t *testing.T) {
+	s := marionettetest.NewServer()
+	defer s.Close()
+	s.HandleValue("getTitle", "title")
+
+	tr := &MarionetteTransport{}
+	if err := tr.Connect(s.Host(), s.Port()); err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					tr.Send("getTitle", nil) // fails while disconnected
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 5; i++ {
+		tr.Close()
+		if err := tr.Connect(s.Host(), s.Port()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+}
+
+type notFoundFinder struct{}
+
+func (notFoundFinder) FindElement(by By, value string) (*WebElement, error) {
+	return nil, errors.New("not found")
+}
+
+func (notFoundFinder) FindElements(by By, value string) ([]*WebElement, error) {
+	return nil, errors.New("not found")
+}
+
+func TestSendDefaultTimeoutFake(t *testing.T) {
+	c, s := connect(t)
+	hang(t, s, "getTitle")
+
+	saved := defaultTimeout
+	defaultTimeout = 50 * time.Millisecond
+	defer func() { defaultTimeout = saved }()
+
+	_, err := c.Title()
+	var ce *CancelledError
+	if !errors.As(err, &ce) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a *CancelledError for the default timeout, got %#v", err)
+	}
+
+	// a later deadline of the caller replaces the default timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := c.BindContext(ctx).Title(); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) < 150*time.Millisecond {
+		t.Fatalf("expected the deadline of ctx to apply, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestUntilContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	ok, _, err := Wait(notFoundFinder{}).For(time.Minute).UntilContext(ctx, ElementIsPresent(By(ID), "missing"))
+	if ok || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v, %#v", ok, err)
+	}
+
+	if time.Since(start) > time.Second {
+		t.Fatalf("wait was not aborted on cancel")
+	}
+}
@@ -0,0 +1,179 @@
+package visual
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+
+	marionette "github.com/njasm/marionette_client"
+)
+
+// Checker compares page and element screenshots against the PNG baselines of
+// a directory, <Dir>/<name>.png.
+//
+// On a mismatch, or a missing baseline, the capture is written to
+// <name>.actual.png and the diff image to <name>.diff.png. In Update mode the
+// captures are written as the new baselines instead, and never fail.
+type Checker struct {
+	Dir    string
+	Update bool
+	Options
+
+	// IgnoreElements are not compared, in addition to Options.Ignore. They
+	// are located when the screenshot is taken.
+	IgnoreElements []*marionette.WebElement
+}
+
+// MismatchError is returned by Checker when a capture differs from its
+// baseline by more than Options.MaxMismatch.
+type MismatchError struct {
+	Name   string
+	Result *Result
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("visual: %v differs from its baseline by %.2f%% (%d pixels)", e.Name, e.Result.Percent(), e.Result.Mismatched)
+}
+
+// CheckPage compares a screenshot of the document of c, taken with opts,
+// against the baseline name.
+func (ck *Checker) CheckPage(c *marionette.Client, name string, opts *marionette.ScreenshotOptions) (*Result, error) {
+	if opts == nil {
+		opts = &marionette.ScreenshotOptions{}
+	}
+
+	img, err := c.ScreenshotImage(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	regions, err := ck.regions(c, nil, opts.Full)
+	if err != nil {
+		return nil, err
+	}
+
+	return ck.check(name, img, regions)
+}
+
+// CheckElement compares a screenshot of e, taken with opts, against the
+// baseline name.
+func (ck *Checker) CheckElement(e *marionette.WebElement, name string, opts *marionette.ScreenshotOptions) (*Result, error) {
+	img, err := e.ScreenshotImage(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	regions, err := ck.regions(e.Client(), e, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return ck.check(name, img, regions)
+}
+
+// regionsScript returns the rects, in device pixels, of the elements
+// arguments[2] relative to the element arguments[0], or to the viewport, or
+// the document if arguments[1] is true.
+const regionsScript = `let [target, full, elements] = arguments;
+let origin = target ? target.getBoundingClientRect() :
+  {left: full ? -window.scrollX : 0, top: full ? -window.scrollY : 0};
+let ratio = window.devicePixelRatio;
+return elements.map(e => {
+  let r = e.getBoundingClientRect();
+  return [(r.left - origin.left) * ratio, (r.top - origin.top) * ratio,
+    (r.right - origin.left) * ratio, (r.bottom - origin.top) * ratio];
+});`
+
+// regions returns the regions of the screenshot not compared.
+func (ck *Checker) regions(c *marionette.Client, target *marionette.WebElement, full bool) ([]image.Rectangle, error) {
+	regions := append([]image.Rectangle(nil), ck.Ignore...)
+	if len(ck.IgnoreElements) == 0 {
+		return regions, nil
+	}
+
+	var rects [][4]float64
+	if err := c.ExecuteScriptInto(&rects, regionsScript, target, full, ck.IgnoreElements); err != nil {
+		return nil, err
+	}
+
+	for _, r := range rects {
+		regions = append(regions, image.Rect(
+			int(math.Floor(r[0])), int(math.Floor(r[1])),
+			int(math.Ceil(r[2])), int(math.Ceil(r[3]))))
+	}
+
+	return regions, nil
+}
+
+func (ck *Checker) check(name string, img image.Image, regions []image.Rectangle) (*Result, error) {
+	path := filepath.Join(ck.Dir, name+".png")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+
+	if ck.Update {
+		return nil, writePNG(path, img)
+	}
+
+	baseline, err := readPNG(path)
+	if errors.Is(err, os.ErrNotExist) {
+		if werr := writePNG(filepath.Join(ck.Dir, name+".actual.png"), img); werr != nil {
+			return nil, werr
+		}
+
+		return nil, fmt.Errorf("visual: no baseline for %v: %w", name, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	opts := ck.Options
+	opts.Ignore = regions
+	r := Compare(baseline, img, &opts)
+	if r.Percent() <= ck.MaxMismatch && sameSize(baseline, img) {
+		return r, nil
+	}
+
+	if err := writePNG(filepath.Join(ck.Dir, name+".actual.png"), img); err != nil {
+		return nil, err
+	}
+
+	if err := writePNG(filepath.Join(ck.Dir, name+".diff.png"), r.Diff); err != nil {
+		return nil, err
+	}
+
+	return r, &MismatchError{Name: name, Result: r}
+}
+
+func sameSize(a, b image.Image) bool {
+	return a.Bounds().Size() == b.Bounds().Size()
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,108 @@
+package visual
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	marionette "github.com/njasm/marionette_client"
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func connect(t *testing.T) (*marionette.Client, *marionettetest.Server) {
+	s := marionettetest.NewServer()
+	c := marionette.NewClient()
+	if err := c.Connect(s.Host(), s.Port()); err != nil {
+		s.Close()
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		c.Close()
+		s.Close()
+	})
+
+	return c, s
+}
+
+func handleScreenshot(s *marionettetest.Server, img image.Image) {
+	var b bytes.Buffer
+	png.Encode(&b, img)
+	s.HandleValue("takeScreenshot", base64.StdEncoding.EncodeToString(b.Bytes()))
+}
+
+func TestCheckerFake(t *testing.T) {
+	c, s := connect(t)
+	dir := t.TempDir()
+
+	handleScreenshot(s, page(10, 10, 2, 2))
+	ck := &Checker{Dir: dir, Update: true}
+	if _, err := ck.CheckPage(c, "home", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "home.png")); err != nil {
+		t.Fatalf("expected the baseline to be written: %v", err)
+	}
+
+	ck.Update = false
+	if r, err := ck.CheckPage(c, "home", nil); err != nil || r.Mismatched != 0 {
+		t.Fatalf("expected the capture to match, got %+v %v", r, err)
+	}
+
+	handleScreenshot(s, page(10, 10, 5, 2))
+	r, err := ck.CheckPage(c, "home", nil)
+	var me *MismatchError
+	if !errors.As(err, &me) || me.Result != r || r.Mismatched != 24 {
+		t.Fatalf("expected a mismatch, got %+v %v", r, err)
+	}
+
+	for _, name := range []string{"home.actual.png", "home.diff.png"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("expected %v to be written: %v", name, err)
+		}
+	}
+
+	ck.MaxMismatch = 25
+	if _, err := ck.CheckPage(c, "home", nil); err != nil {
+		t.Fatalf("expected the mismatch to be tolerated, got %v", err)
+	}
+
+	if _, err := ck.CheckPage(c, "missing", nil); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected a missing baseline error, got %v", err)
+	}
+}
+
+func TestCheckerIgnoreElementsFake(t *testing.T) {
+	c, s := connect(t)
+	dir := t.TempDir()
+
+	handleScreenshot(s, page(10, 10, 2, 2))
+	ck := &Checker{Dir: dir, Update: true}
+	if _, err := ck.CheckElement(c.ElementFromID("e1"), "banner", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the square moved within the ignored element
+	handleScreenshot(s, page(10, 10, 5, 2))
+	s.HandleValue("executeScript", [][]float64{{1.5, 1.5, 9.2, 6}})
+	ck.Update = false
+	ck.IgnoreElements = []*marionette.WebElement{c.ElementFromID("e2")}
+	r, err := ck.CheckElement(c.ElementFromID("e1"), "banner", nil)
+	if err != nil || r.Pixels != 100-9*5 {
+		t.Fatalf("expected the element region not to be compared, got %+v %v", r, err)
+	}
+
+	var p struct {
+		Args []interface{}
+	}
+	s.Received("executeScript")[0].Decode(&p)
+	if len(p.Args) != 3 || p.Args[0].(map[string]interface{})[marionette.WEBDRIVER_ELEMENT_KEY] != "e1" || p.Args[1] != false {
+		t.Fatalf("unexpected script arguments %v", p.Args)
+	}
+}
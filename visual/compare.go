@@ -0,0 +1,201 @@
+// Package visual compares screenshots against stored baselines, for visual
+// regression tests.
+//
+//	ck := &visual.Checker{Dir: "testdata/baselines", Update: *update}
+//	ck.Tolerance = 8
+//	ck.IgnoreElements = []*marionette.WebElement{clock}
+//	if _, err := ck.CheckPage(client, "home", &marionette.ScreenshotOptions{Full: true}); err != nil {
+//		t.Fatal(err) // the capture and a diff image are next to the baseline
+//	}
+package visual
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Colors of the diff image.
+var (
+	MismatchColor     color.Color = color.RGBA{255, 0, 0, 255}
+	AntiAliasingColor color.Color = color.RGBA{255, 200, 0, 255}
+)
+
+// Options configure a comparison.
+type Options struct {
+	// Tolerance is the largest difference of a color channel, 0 to 255,
+	// between two pixels that still match.
+	Tolerance uint8
+
+	// AntiAliasing ignores the mismatched pixels that look like anti-aliasing:
+	// a blend of the colors around them in the other image, as when an edge
+	// shifts or fonts are rendered differently.
+	AntiAliasing bool
+
+	// Ignore are regions, in pixels of the images, not compared.
+	Ignore []image.Rectangle
+
+	// MaxMismatch is the percentage of mismatched pixels tolerated by a
+	// Checker.
+	MaxMismatch float64
+}
+
+// Result is the outcome of a comparison.
+type Result struct {
+	// Mismatched is the number of pixels that differ, AntiAliased the number
+	// of the differing pixels ignored as anti-aliasing, and Pixels the number
+	// of pixels compared, those of the ignored regions excluded.
+	Mismatched  int
+	AntiAliased int
+	Pixels      int
+
+	// Diff shows the baseline faded, its mismatched pixels in MismatchColor
+	// and the anti-aliased ones in AntiAliasingColor.
+	Diff *image.RGBA
+}
+
+// Percent returns the percentage of mismatched pixels.
+func (r *Result) Percent() float64 {
+	if r.Pixels == 0 {
+		return 0
+	}
+
+	return float64(r.Mismatched) * 100 / float64(r.Pixels)
+}
+
+// Compare compares actual against baseline. Images of different sizes are
+// compared over the larger bounds, the pixels outside of one of them
+// mismatching.
+func Compare(baseline, actual image.Image, opts *Options) *Result {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	b, a := rgba(baseline), rgba(actual)
+	bounds := b.Bounds().Union(a.Bounds())
+	r := &Result{Diff: image.NewRGBA(bounds)}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := image.Pt(x, y)
+			if ignored(p, opts.Ignore) {
+				r.Diff.SetRGBA(x, y, fade(b, p))
+				continue
+			}
+
+			r.Pixels++
+			switch {
+			case !p.In(b.Bounds()) || !p.In(a.Bounds()):
+				r.Mismatched++
+				r.Diff.Set(x, y, MismatchColor)
+			case near(b.RGBAAt(x, y), a.RGBAAt(x, y), opts.Tolerance):
+				r.Diff.SetRGBA(x, y, fade(b, p))
+			case opts.AntiAliasing && (blended(a.RGBAAt(x, y), b, p, opts.Tolerance) || blended(b.RGBAAt(x, y), a, p, opts.Tolerance)):
+				r.AntiAliased++
+				r.Diff.Set(x, y, AntiAliasingColor)
+			default:
+				r.Mismatched++
+				r.Diff.Set(x, y, MismatchColor)
+			}
+		}
+	}
+
+	return r
+}
+
+// rgba returns img as an *image.RGBA.
+func rgba(img image.Image) *image.RGBA {
+	if r, ok := img.(*image.RGBA); ok {
+		return r
+	}
+
+	r := image.NewRGBA(img.Bounds())
+	draw.Draw(r, r.Bounds(), img, img.Bounds().Min, draw.Src)
+	return r
+}
+
+func ignored(p image.Point, regions []image.Rectangle) bool {
+	for _, r := range regions {
+		if p.In(r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func near(c1, c2 color.RGBA, tolerance uint8) bool {
+	return diff(c1.R, c2.R) <= tolerance &&
+		diff(c1.G, c2.G) <= tolerance &&
+		diff(c1.B, c2.B) <= tolerance &&
+		diff(c1.A, c2.A) <= tolerance
+}
+
+func diff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+
+	return b - a
+}
+
+// blended reports whether c lies between the colors of the 8 neighbors of p
+// in img, which have more than one color.
+func blended(c color.RGBA, img *image.RGBA, p image.Point, tolerance uint8) bool {
+	lo := color.RGBA{255, 255, 255, 255}
+	var hi color.RGBA
+	area := image.Rect(p.X-1, p.Y-1, p.X+2, p.Y+2).Intersect(img.Bounds())
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			if x == p.X && y == p.Y {
+				continue
+			}
+
+			n := img.RGBAAt(x, y)
+			lo = color.RGBA{min8(lo.R, n.R), min8(lo.G, n.G), min8(lo.B, n.B), min8(lo.A, n.A)}
+			hi = color.RGBA{max8(hi.R, n.R), max8(hi.G, n.G), max8(hi.B, n.B), max8(hi.A, n.A)}
+		}
+	}
+
+	if near(lo, hi, tolerance) {
+		return false // flat area, not an edge
+	}
+
+	return within(c.R, lo.R, hi.R, tolerance) &&
+		within(c.G, lo.G, hi.G, tolerance) &&
+		within(c.B, lo.B, hi.B, tolerance) &&
+		within(c.A, lo.A, hi.A, tolerance)
+}
+
+func within(v, lo, hi, tolerance uint8) bool {
+	return int(v)+int(tolerance) >= int(lo) && int(v) <= int(hi)+int(tolerance)
+}
+
+func min8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+func max8(a, b uint8) uint8 {
+	if a > b {
+		return a
+	}
+
+	return b
+}
+
+// fade returns the color of img at p, grayed and lightened, white outside of
+// img.
+func fade(img *image.RGBA, p image.Point) color.RGBA {
+	if !p.In(img.Bounds()) {
+		return color.RGBA{255, 255, 255, 255}
+	}
+
+	c := img.RGBAAt(p.X, p.Y)
+	y := (299*uint32(c.R) + 587*uint32(c.G) + 114*uint32(c.B)) / 1000
+	v := uint8(255 - (255-y)/4)
+	return color.RGBA{v, v, v, 255}
+}
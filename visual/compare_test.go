@@ -0,0 +1,84 @@
+package visual
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+var (
+	white = color.RGBA{255, 255, 255, 255}
+	black = color.RGBA{0, 0, 0, 255}
+)
+
+// page returns a w x h white image with a black square of side 4 at x, y.
+func page(w, h, x, y int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for py := 0; py < h; py++ {
+		for px := 0; px < w; px++ {
+			if px >= x && px < x+4 && py >= y && py < y+4 {
+				img.SetRGBA(px, py, black)
+			} else {
+				img.SetRGBA(px, py, white)
+			}
+		}
+	}
+
+	return img
+}
+
+func TestCompare(t *testing.T) {
+	baseline := page(10, 10, 2, 2)
+
+	r := Compare(baseline, page(10, 10, 2, 2), nil)
+	if r.Mismatched != 0 || r.Pixels != 100 || r.Percent() != 0 {
+		t.Fatalf("expected identical images to match, got %+v", r)
+	}
+
+	moved := page(10, 10, 5, 2)
+	r = Compare(baseline, moved, nil)
+	if r.Mismatched != 24 || r.Percent() != 24 {
+		t.Fatalf("expected 24 mismatched pixels, got %v", r.Mismatched)
+	}
+
+	if r.Diff.RGBAAt(2, 2) != MismatchColor || r.Diff.RGBAAt(0, 0) != white || r.Diff.RGBAAt(5, 2) == MismatchColor {
+		t.Fatal("unexpected diff image")
+	}
+
+	r = Compare(baseline, moved, &Options{Ignore: []image.Rectangle{image.Rect(0, 0, 10, 6)}})
+	if r.Mismatched != 0 || r.Pixels != 40 {
+		t.Fatalf("expected the ignored region not to be compared, got %+v", r)
+	}
+
+	r = Compare(baseline, page(12, 10, 2, 2), nil)
+	if r.Mismatched != 20 || r.Diff.Bounds().Dx() != 12 {
+		t.Fatalf("expected the extra columns to mismatch, got %v", r.Mismatched)
+	}
+}
+
+func TestCompareTolerance(t *testing.T) {
+	baseline, actual := page(10, 10, 2, 2), page(10, 10, 2, 2)
+	actual.SetRGBA(0, 0, color.RGBA{250, 252, 255, 255})
+
+	if r := Compare(baseline, actual, nil); r.Mismatched != 1 {
+		t.Fatalf("expected 1 mismatched pixel, got %v", r.Mismatched)
+	}
+
+	if r := Compare(baseline, actual, &Options{Tolerance: 5}); r.Mismatched != 0 {
+		t.Fatalf("expected the pixel to match within tolerance, got %v", r.Mismatched)
+	}
+}
+
+func TestCompareAntiAliasing(t *testing.T) {
+	baseline, actual := page(10, 10, 2, 2), page(10, 10, 2, 2)
+
+	// a gray edge, and a gray pixel in a flat area
+	gray := color.RGBA{128, 128, 128, 255}
+	actual.SetRGBA(6, 3, gray)
+	actual.SetRGBA(8, 8, gray)
+
+	r := Compare(baseline, actual, &Options{AntiAliasing: true})
+	if r.Mismatched != 1 || r.AntiAliased != 1 || r.Diff.RGBAAt(6, 3) != AntiAliasingColor || r.Diff.RGBAAt(8, 8) != MismatchColor {
+		t.Fatalf("expected the edge pixel to be anti-aliasing, got %+v", r)
+	}
+}
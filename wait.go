@@ -1,13 +1,35 @@
 package marionette_client
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"time"
 )
 
+// Defaults of a Waiter.
+const (
+	DefaultWaitTimeout  = 10 * time.Second
+	DefaultPollInterval = 500 * time.Millisecond
+)
+
+// Waiter polls a Condition until it holds or the timeout expires. Its
+// settings are chained:
+//
+//	ok, e, err := Wait(client).
+//		For(30 * time.Second).
+//		Poll(100*time.Millisecond).Backoff(2, 2*time.Second).
+//		Ignore(ErrElementNotInteractable).
+//		Message("checkout button never became clickable").
+//		Until(ElementIsClickable(By(ID), "checkout"))
 type Waiter struct {
-	f Finder
-	d time.Duration // Milliseconds
+	f       Finder
+	d       time.Duration
+	poll    time.Duration
+	factor  float64
+	max     time.Duration
+	ignored []error
+	message string
 }
 
 type Finder interface {
@@ -15,40 +37,206 @@ type Finder interface {
 	FindElements(by By, value string) ([]*WebElement, error)
 }
 
+// Wait returns a Waiter polling conditions against f, every
+// DefaultPollInterval for DefaultWaitTimeout.
 func Wait(f Finder) *Waiter {
-	return &Waiter{f, time.Duration(1)}
+	return &Waiter{f: f, d: DefaultWaitTimeout, poll: DefaultPollInterval}
 }
 
+// For sets how long to wait. The condition is checked at least once, so a
+// zero or negative d checks it once.
 func (w *Waiter) For(d time.Duration) *Waiter {
-	if d < 0 || d > time.Minute*10 {
-		w.d = time.Duration(time.Second)
-		return w
+	if d < 0 {
+		d = 0
 	}
 
 	w.d = d
 	return w
 }
 
-func (w *Waiter) Until(f func(c Finder) (bool, *WebElement, error)) (bool, *WebElement, error) {
-	firstRun := true
-	delta := time.Now()
-	for time.Since(delta) < w.d || firstRun {
-		firstRun = false
+// Poll sets the interval between two checks of the condition.
+func (w *Waiter) Poll(d time.Duration) *Waiter {
+	if d < 0 {
+		d = 0
+	}
+
+	w.poll = d
+	return w
+}
+
+// Backoff multiplies the poll interval by factor after every check, up to
+// max. A max of zero doesn't bound the interval, other than by the time left.
+func (w *Waiter) Backoff(factor float64, max time.Duration) *Waiter {
+	w.factor = factor
+	w.max = max
+	return w
+}
+
+// Ignore keeps polling when the condition fails with one of errs, matched with
+// errors.Is, e.g. ErrElementNotInteractable while an overlay fades out. Driver
+// errors of an element, alert or frame not found, or of a stale element, are
+// always ignored.
+func (w *Waiter) Ignore(errs ...error) *Waiter {
+	w.ignored = append(w.ignored, errs...)
+	return w
+}
+
+// Message sets the message of the *TimeoutError returned when the condition
+// never held.
+func (w *Waiter) Message(msg string) *Waiter {
+	w.message = msg
+	return w
+}
+
+// TimeoutError is returned when a condition didn't hold before the timeout of
+// its Waiter. It matches ErrTimeout with errors.Is; the last error the
+// condition failed with, if any, is in LastErr.
+type TimeoutError struct {
+	Message  string
+	Timeout  time.Duration
+	Attempts int
+	LastErr  error
+}
+
+func (e *TimeoutError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = "Condition never occurred."
+	}
+
+	if e.LastErr == nil {
+		return fmt.Sprintf("%v (%v, %d attempts)", msg, e.Timeout, e.Attempts)
+	}
+
+	return fmt.Sprintf("%v (%v, %d attempts, last error: %v)", msg, e.Timeout, e.Attempts, e.LastErr)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return ErrTimeout
+}
+
+func (w *Waiter) Until(f Condition) (bool, *WebElement, error) {
+	return w.UntilContext(context.Background(), f)
+}
+
+// UntilContext is like Until but stops polling as soon as ctx is done, returning
+// a *CancelledError. When the Finder is a *Client or *WebElement the condition
+// runs against a copy bound to ctx, so a command in flight is aborted too.
+func (w *Waiter) UntilContext(ctx context.Context, f Condition) (bool, *WebElement, error) {
+	finder := bindFinder(ctx, w.f)
+	var last error
+	e, err := WaitFor(ctx, w, func(context.Context) (*WebElement, bool, error) {
+		ok, e, err := f(finder)
+		last = err
+		return e, ok, err
+	})
+	if err != nil {
+		return false, nil, err
+	}
 
-		ok, value, err := f(w.f)
+	return true, e, last
+}
+
+// WaitFor polls fn with the timeout, interval and ignored errors of w until it
+// reports ok, and returns its value. fn gets ctx, to bind the commands it sends
+// with Client.BindContext:
+//
+//	handles, err := WaitFor(ctx, Wait(client).For(5*time.Second), func(ctx context.Context) ([]string, bool, error) {
+//		handles, err := client.BindContext(ctx).WindowHandles()
+//		return handles, len(handles) == 2, err
+//	})
+//
+// Like Until, WaitFor returns a *CancelledError once ctx is done, a driver
+// error fn failed with unless it may be retried or is ignored by w, and a
+// *TimeoutError when fn never reported ok.
+func WaitFor[T any](ctx context.Context, w *Waiter, fn func(ctx context.Context) (T, bool, error)) (T, error) {
+	var zero T
+	deadline := time.Now().Add(w.d)
+	interval := w.poll
+	attempts := 0
+	var last error
+	for {
+		if err := ctx.Err(); err != nil {
+			return zero, &CancelledError{Err: err}
+		}
+
+		attempts++
+		value, ok, err := fn(ctx)
 		if err != nil {
-			_, de := err.(DriverError)
-			if de {
-				return false, nil, err
+			var ce *CancelledError
+			if errors.As(err, &ce) || errors.Is(err, ErrClosed) {
+				return zero, err
 			}
+
+			// driver errors end the wait, unless the element, alert or frame
+			// may still show up, or the caller asked to ignore them.
+			var de *DriverError
+			if errors.As(err, &de) && !retryable(err) && !w.ignores(err) {
+				return zero, err
+			}
+
+			last = err
 		}
 
 		if ok {
-			return true, value, err
+			return value, nil
+		}
+
+		left := time.Until(deadline)
+		if left <= 0 {
+			break
+		}
+
+		sleep := interval
+		if sleep > left {
+			sleep = left
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, &CancelledError{Err: ctx.Err()}
+		case <-timer.C:
+		}
+
+		interval = w.next(interval)
+	}
+
+	return zero, &TimeoutError{Message: w.message, Timeout: w.d, Attempts: attempts, LastErr: last}
+}
+
+func (w *Waiter) ignores(err error) bool {
+	for _, ignored := range w.ignored {
+		if errors.Is(err, ignored) {
+			return true
 		}
+	}
+
+	return false
+}
+
+// next returns the poll interval following interval.
+func (w *Waiter) next(interval time.Duration) time.Duration {
+	if w.factor <= 1 {
+		return interval
+	}
+
+	next := time.Duration(float64(interval) * w.factor)
+	if w.max > 0 && next > w.max {
+		next = w.max
+	}
+
+	return next
+}
 
-		time.Sleep(time.Second)
+func bindFinder(ctx context.Context, f Finder) Finder {
+	switch v := f.(type) {
+	case *Client:
+		return v.BindContext(ctx)
+	case *WebElement:
+		return v.BindContext(ctx)
 	}
 
-	return false, nil, errors.New("Condition never occurred.")
+	return f
 }
@@ -0,0 +1,144 @@
+package marionette_client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func TestWaiterTimeoutError(t *testing.T) {
+	f := &errFinder{err: &DriverError{ErrorType: "no such element"}}
+	ok, _, err := Wait(f).For(50 * time.Millisecond).Poll(10 * time.Millisecond).Message("no #id").Until(ElementIsPresent(By(ID), "id"))
+	if ok {
+		t.Fatal("expected the wait to time out")
+	}
+
+	var te *TimeoutError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected a *TimeoutError, got %#v", err)
+	}
+
+	if te.Attempts != f.calls || te.Attempts < 3 {
+		t.Errorf("expected the attempts to be counted, got %v for %v calls", te.Attempts, f.calls)
+	}
+
+	if !errors.Is(te.LastErr, ErrNoSuchElement) {
+		t.Errorf("expected the last condition error, got %#v", te.LastErr)
+	}
+
+	if !errors.Is(err, ErrTimeout) || errors.Is(err, ErrNoSuchElement) {
+		t.Errorf("expected the error to match ErrTimeout only, got %#v", err)
+	}
+
+	if msg := err.Error(); !strings.HasPrefix(msg, "no #id") || !strings.Contains(msg, "no such element") {
+		t.Errorf("unexpected message %q", msg)
+	}
+}
+
+func TestWaiterIgnore(t *testing.T) {
+	f := &errFinder{err: &DriverError{ErrorType: "element not interactable"}}
+	_, _, err := Wait(f).For(time.Minute).Until(ElementIsPresent(By(ID), "id"))
+	if !errors.Is(err, ErrElementNotInteractable) || f.calls != 1 {
+		t.Fatalf("expected the wait to stop at once, got %#v after %v calls", err, f.calls)
+	}
+
+	f.calls = 0
+	_, _, err = Wait(f).For(30 * time.Millisecond).Poll(5 * time.Millisecond).Ignore(ErrElementNotInteractable).Until(ElementIsPresent(By(ID), "id"))
+	var te *TimeoutError
+	if !errors.As(err, &te) || f.calls < 2 {
+		t.Fatalf("expected the wait to poll until the timeout, got %#v after %v calls", err, f.calls)
+	}
+}
+
+func TestWaiterFor(t *testing.T) {
+	f := &errFinder{err: errors.New("not yet")}
+	start := time.Now()
+	_, _, err := Wait(f).For(-time.Second).Until(ElementIsPresent(By(ID), "id"))
+	if f.calls != 1 || time.Since(start) > 100*time.Millisecond {
+		t.Fatalf("expected a single check, got %v calls in %v", f.calls, time.Since(start))
+	}
+
+	var te *TimeoutError
+	if !errors.As(err, &te) || te.LastErr != f.err {
+		t.Fatalf("expected a *TimeoutError with the condition error, got %#v", err)
+	}
+
+	if w := Wait(f).For(time.Hour); w.d != time.Hour {
+		t.Fatalf("expected For not to clamp, got %v", w.d)
+	}
+}
+
+func TestWaiterBackoff(t *testing.T) {
+	w := Wait(notFoundFinder{}).Poll(10*time.Millisecond).Backoff(2, 35*time.Millisecond)
+
+	interval := w.poll
+	var got []time.Duration
+	for i := 0; i < 4; i++ {
+		interval = w.next(interval)
+		got = append(got, interval)
+	}
+
+	want := []time.Duration{20 * time.Millisecond, 35 * time.Millisecond, 35 * time.Millisecond, 35 * time.Millisecond}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected intervals %v, got %v", want, got)
+		}
+	}
+
+	if next := Wait(notFoundFinder{}).next(time.Second); next != time.Second {
+		t.Fatalf("expected a constant interval without backoff, got %v", next)
+	}
+}
+
+func TestWaitForFake(t *testing.T) {
+	c, s := connect(t)
+	var calls int32
+	s.Handle("getTitle", func(cmd marionettetest.Command) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return marionettetest.Value("Loading"), nil
+		}
+
+		return marionettetest.Value("Checkout"), nil
+	})
+
+	ctx := context.Background()
+	w := Wait(c).For(time.Second).Poll(5 * time.Millisecond)
+	title, err := WaitFor(ctx, w, func(ctx context.Context) (string, bool, error) {
+		title, err := c.BindContext(ctx).Title()
+		return title, title == "Checkout", err
+	})
+	if err != nil || title != "Checkout" || atomic.LoadInt32(&calls) != 3 {
+		t.Fatalf("expected the title after 3 polls, got %q, %v after %v calls", title, err, calls)
+	}
+
+	n, err := WaitFor(ctx, Wait(c).For(20*time.Millisecond).Poll(5*time.Millisecond), func(ctx context.Context) (int, bool, error) {
+		return 42, false, nil
+	})
+	var te *TimeoutError
+	if n != 0 || !errors.As(err, &te) {
+		t.Fatalf("expected the zero value and a *TimeoutError, got %v, %#v", n, err)
+	}
+}
+
+func TestWaitForConditionFake(t *testing.T) {
+	c, s := connect(t)
+	var calls int32
+	s.Handle("findElement", func(cmd marionettetest.Command) (interface{}, error) {
+		if atomic.AddInt32(&calls, 1) < 2 {
+			return nil, &marionettetest.Error{Type: "no such element"}
+		}
+
+		return marionettetest.Value(marionettetest.Element("e1")), nil
+	})
+
+	w := Wait(c).For(time.Second).Poll(5 * time.Millisecond)
+	e, err := WaitFor(context.Background(), w, ElementIsPresent(By(ID), "q").Func(c))
+	if err != nil || e == nil || e.Id() != "e1" {
+		t.Fatalf("expected element e1, got %#v, %v", e, err)
+	}
+}
@@ -0,0 +1,427 @@
+package webdriverd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	marionette "github.com/njasm/marionette_client"
+)
+
+// handlers maps the endpoint names of command.Endpoints to the Marionette
+// commands they translate to. New Session, Delete Session and Status are
+// served by the Server itself.
+var handlers = map[string]handler{
+	"Get Timeouts":    passthrough("getTimeouts"),
+	"Set Timeouts":    setTimeouts,
+	"Navigate To":     navigateTo,
+	"Get Current URL": getCurrentURL,
+	"Back": func(c *marionette.Client, r *request) (interface{}, error) {
+		return nil, c.Back()
+	},
+	"Forward": func(c *marionette.Client, r *request) (interface{}, error) {
+		return nil, c.Forward()
+	},
+	"Refresh": func(c *marionette.Client, r *request) (interface{}, error) {
+		return nil, c.Refresh()
+	},
+	"Get Title": func(c *marionette.Client, r *request) (interface{}, error) {
+		return c.Title()
+	},
+	"Get Window Handle": func(c *marionette.Client, r *request) (interface{}, error) {
+		return c.CurrentWindowHandle()
+	},
+	"Close Window":     closeWindow,
+	"Switch To Window": switchToWindow,
+	"Get Window Handles": func(c *marionette.Client, r *request) (interface{}, error) {
+		return c.WindowHandles()
+	},
+	"New Window":      passthroughBody("newWindow"),
+	"Switch To Frame": switchToFrame,
+	"Switch To Parent Frame": func(c *marionette.Client, r *request) (interface{}, error) {
+		return nil, c.SwitchToParentFrame()
+	},
+	"Get Window Rect":            passthrough("getWindowRect"),
+	"Set Window Rect":            passthroughBody("setWindowRect"),
+	"Maximize Window":            passthrough("maximizeWindow"),
+	"Minimize Window":            passthrough("minimizeWindow"),
+	"Fullscreen Window":          passthrough("fullscreenWindow"),
+	"Get Window Size":            getWindowSize,
+	"Set Window Size":            setWindowSize,
+	"Get Active Element":         passthrough("getActiveElement"),
+	"Find Element":               findElement("findElement"),
+	"Find Elements":              findElement("findElements"),
+	"Find Element From Element":  findElement("findElement"),
+	"Find Elements From Element": findElement("findElements"),
+	"Is Element Selected": func(c *marionette.Client, r *request) (interface{}, error) {
+		return element(c, r).Selected()
+	},
+	"Get Element Attribute": getElementAttribute,
+	"Get Element Property":  getElementProperty,
+	"Get Element CSS Value": func(c *marionette.Client, r *request) (interface{}, error) {
+		return element(c, r).CssValue(r.PathValue("property_name"))
+	},
+	"Get Element Text": func(c *marionette.Client, r *request) (interface{}, error) {
+		return element(c, r).Text()
+	},
+	"Get Element Tag Name": func(c *marionette.Client, r *request) (interface{}, error) {
+		return element(c, r).TagName()
+	},
+	"Get Element Rect": getElementRect,
+	"Is Element Enabled": func(c *marionette.Client, r *request) (interface{}, error) {
+		return element(c, r).Enabled()
+	},
+	"Element Click": func(c *marionette.Client, r *request) (interface{}, error) {
+		return nil, element(c, r).Click()
+	},
+	"Element Clear": func(c *marionette.Client, r *request) (interface{}, error) {
+		return nil, element(c, r).Clear()
+	},
+	"Element Send Keys": elementSendKeys,
+	"Get Page Source": func(c *marionette.Client, r *request) (interface{}, error) {
+		return value(c.PageSource())
+	},
+	"Execute Script":       executeScript("executeScript"),
+	"Execute Async Script": executeScript("executeAsyncScript"),
+	"Get All Cookies": func(c *marionette.Client, r *request) (interface{}, error) {
+		return c.GetCookies()
+	},
+	"Get Named Cookie":   getNamedCookie,
+	"Add Cookie":         passthroughBody("addCookie"),
+	"Delete Cookie":      deleteCookie,
+	"Delete All Cookies": passthrough("deleteAllCookies"),
+	"Perform Actions":    passthroughBody("performActions"),
+	"Release Actions":    passthrough("releaseActions"),
+	"Dismiss Alert": func(c *marionette.Client, r *request) (interface{}, error) {
+		return nil, c.DismissDialog()
+	},
+	"Accept Alert": func(c *marionette.Client, r *request) (interface{}, error) {
+		return nil, c.AcceptDialog()
+	},
+	"Get Alert Text": func(c *marionette.Client, r *request) (interface{}, error) {
+		return c.TextFromDialog()
+	},
+	"Send Alert Text": sendAlertText,
+	"Take Screenshot": takeScreenshot,
+	"Take Element Screenshot": func(c *marionette.Client, r *request) (interface{}, error) {
+		return screenshot(element(c, r).Screenshot())
+	},
+}
+
+// passthrough sends the command without parameters.
+func passthrough(name string) handler {
+	return func(c *marionette.Client, r *request) (interface{}, error) {
+		return value(c.Command(name, nil))
+	}
+}
+
+// passthroughBody sends the command with the request body as parameters, for
+// the commands whose parameters are the same in both protocols.
+func passthroughBody(name string) handler {
+	return func(c *marionette.Client, r *request) (interface{}, error) {
+		var params map[string]interface{}
+		if err := r.decode(&params); err != nil {
+			return nil, err
+		}
+
+		return value(c.Command(name, params))
+	}
+}
+
+// value returns the value of a Marionette response. Results wrapped as
+// {"value": ...} are unwrapped, the others are returned as they are.
+func value(resp *marionette.Response, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Value == "" {
+		return nil, nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(resp.Value), &v); err != nil {
+		return nil, err
+	}
+
+	if m, ok := v.(map[string]interface{}); ok && len(m) == 1 {
+		if inner, found := m["value"]; found {
+			return inner, nil
+		}
+	}
+
+	return v, nil
+}
+
+func element(c *marionette.Client, r *request) *marionette.WebElement {
+	return c.ElementFromID(r.PathValue("element_id"))
+}
+
+func setTimeouts(c *marionette.Client, r *request) (interface{}, error) {
+	var body struct {
+		Implicit *int `json:"implicit"`
+		PageLoad *int `json:"pageLoad"`
+		Script   *int `json:"script"`
+	}
+
+	if err := r.decode(&body); err != nil {
+		return nil, err
+	}
+
+	if body.Implicit != nil {
+		if _, err := c.SetSearchTimeout(*body.Implicit); err != nil {
+			return nil, err
+		}
+	}
+
+	if body.PageLoad != nil {
+		if _, err := c.SetPageTimeout(*body.PageLoad); err != nil {
+			return nil, err
+		}
+	}
+
+	if body.Script != nil {
+		if _, err := c.SetScriptTimeout(*body.Script); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
+
+func navigateTo(c *marionette.Client, r *request) (interface{}, error) {
+	var body struct {
+		URL *string `json:"url"`
+	}
+
+	if err := r.decode(&body); err != nil {
+		return nil, err
+	}
+
+	if body.URL == nil {
+		return nil, invalidArgument("Missing url.")
+	}
+
+	_, err := c.Navigate(*body.URL)
+	return nil, err
+}
+
+func getCurrentURL(c *marionette.Client, r *request) (interface{}, error) {
+	return c.Url()
+}
+
+func closeWindow(c *marionette.Client, r *request) (interface{}, error) {
+	handles, err := c.CloseWindow()
+	if err != nil || handles != nil {
+		return handles, err
+	}
+
+	return []string{}, nil
+}
+
+func switchToWindow(c *marionette.Client, r *request) (interface{}, error) {
+	var body struct {
+		Handle *string `json:"handle"`
+	}
+
+	if err := r.decode(&body); err != nil {
+		return nil, err
+	}
+
+	if body.Handle == nil {
+		return nil, invalidArgument("Missing handle.")
+	}
+
+	return nil, c.SwitchToWindow(*body.Handle)
+}
+
+func switchToFrame(c *marionette.Client, r *request) (interface{}, error) {
+	var body struct {
+		ID json.RawMessage `json:"id"`
+	}
+
+	if err := r.decode(&body); err != nil {
+		return nil, err
+	}
+
+	var index int
+	var ref map[string]string
+	switch {
+	case len(body.ID) == 0 || string(body.ID) == "null":
+		return nil, c.SwitchToDefaultContent()
+	case json.Unmarshal(body.ID, &index) == nil:
+		return nil, c.SwitchToFrameIndex(index)
+	case json.Unmarshal(body.ID, &ref) == nil && ref[marionette.WEBDRIVER_ELEMENT_KEY] != "":
+		return nil, c.SwitchToFrameElement(c.ElementFromID(ref[marionette.WEBDRIVER_ELEMENT_KEY]))
+	default:
+		return nil, invalidArgument("The frame id must be null, a number or an element reference.")
+	}
+}
+
+func getWindowSize(c *marionette.Client, r *request) (interface{}, error) {
+	w, h, err := c.WindowSize()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]float32{"width": w, "height": h}, nil
+}
+
+func setWindowSize(c *marionette.Client, r *request) (interface{}, error) {
+	var body struct {
+		Width  float32 `json:"width"`
+		Height float32 `json:"height"`
+	}
+
+	if err := r.decode(&body); err != nil {
+		return nil, err
+	}
+
+	w, h, err := c.SetWindowSize(body.Width, body.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]float32{"width": w, "height": h}, nil
+}
+
+// findElement handles the four find endpoints, scoped to the element of the
+// path when there is one.
+func findElement(name string) handler {
+	return func(c *marionette.Client, r *request) (interface{}, error) {
+		var body struct {
+			Using *string `json:"using"`
+			Value *string `json:"value"`
+		}
+
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+
+		if body.Using == nil || body.Value == nil {
+			return nil, invalidArgument("Missing using or value.")
+		}
+
+		params := map[string]interface{}{"using": *body.Using, "value": *body.Value}
+		if id := r.PathValue("element_id"); id != "" {
+			params["element"] = id
+		}
+
+		return value(c.Command(name, params))
+	}
+}
+
+func getElementAttribute(c *marionette.Client, r *request) (interface{}, error) {
+	return value(c.Command("getElementAttribute", map[string]interface{}{
+		"id":   r.PathValue("element_id"),
+		"name": r.PathValue("name"),
+	}))
+}
+
+func getElementProperty(c *marionette.Client, r *request) (interface{}, error) {
+	return value(c.Command("getElementProperty", map[string]interface{}{
+		"id":   r.PathValue("element_id"),
+		"name": r.PathValue("name"),
+	}))
+}
+
+func getElementRect(c *marionette.Client, r *request) (interface{}, error) {
+	rect, err := element(c, r).Rect()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]float32{"x": rect.X, "y": rect.Y, "width": rect.Width, "height": rect.Height}, nil
+}
+
+func elementSendKeys(c *marionette.Client, r *request) (interface{}, error) {
+	var body struct {
+		Text *string `json:"text"`
+	}
+
+	if err := r.decode(&body); err != nil {
+		return nil, err
+	}
+
+	if body.Text == nil {
+		return nil, invalidArgument("Missing text.")
+	}
+
+	return nil, element(c, r).SendKeys(*body.Text)
+}
+
+func executeScript(name string) handler {
+	return func(c *marionette.Client, r *request) (interface{}, error) {
+		var body struct {
+			Script *string       `json:"script"`
+			Args   []interface{} `json:"args"`
+		}
+
+		if err := r.decode(&body); err != nil {
+			return nil, err
+		}
+
+		if body.Script == nil {
+			return nil, invalidArgument("Missing script.")
+		}
+
+		if body.Args == nil {
+			body.Args = []interface{}{}
+		}
+
+		return value(c.Command(name, map[string]interface{}{
+			"script":     *body.Script,
+			"args":       body.Args,
+			"newSandbox": false,
+		}))
+	}
+}
+
+func getNamedCookie(c *marionette.Client, r *request) (interface{}, error) {
+	return c.GetCookie(r.PathValue("name"))
+}
+
+func deleteCookie(c *marionette.Client, r *request) (interface{}, error) {
+	return nil, c.DeleteCookie(r.PathValue("name"))
+}
+
+func sendAlertText(c *marionette.Client, r *request) (interface{}, error) {
+	var body struct {
+		Text *string `json:"text"`
+	}
+
+	if err := r.decode(&body); err != nil {
+		return nil, err
+	}
+
+	if body.Text == nil {
+		return nil, invalidArgument("Missing text.")
+	}
+
+	return nil, c.SendKeysToDialog(*body.Text)
+}
+
+// screenshot unwraps the base64 encoded PNG of a takeScreenshot response.
+// takeScreenshot captures the viewport, as WebDriver does; Marionette
+// captures the whole document unless full is false.
+func takeScreenshot(c *marionette.Client, r *request) (interface{}, error) {
+	b, err := c.ScreenshotPNG(&marionette.ScreenshotOptions{Full: false})
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+func screenshot(raw string, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	var d map[string]string
+	if err := json.Unmarshal([]byte(raw), &d); err != nil {
+		return nil, errors.New("Unexpected screenshot response.")
+	}
+
+	return d["value"], nil
+}
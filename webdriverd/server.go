@@ -0,0 +1,377 @@
+// Package webdriverd exposes the W3C WebDriver HTTP protocol and translates
+// each request into the matching Marionette command, so WebDriver clients
+// written in any language can drive Firefox through this package instead of
+// geckodriver.
+//
+//	s := webdriverd.NewServer("127.0.0.1", 2828)
+//	log.Fatal(http.ListenAndServe(":4444", s))
+package webdriverd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	marionette "github.com/njasm/marionette_client"
+	"github.com/njasm/marionette_client/command"
+)
+
+// Server is an http.Handler serving the W3C WebDriver endpoints. Every new
+// session opens its own connection to Marionette.
+type Server struct {
+	host   string
+	port   int
+	routes []route
+
+	mu       sync.Mutex
+	sessions map[string]*marionette.Client
+}
+
+// route serves one endpoint. Its path template is split into segments, the
+// ones written {name} are variables.
+type route struct {
+	method   string
+	segments []string
+	h        http.HandlerFunc
+}
+
+// request carries the path variables and the JSON body of a WebDriver request.
+type request struct {
+	*http.Request
+	vars map[string]string
+	body json.RawMessage
+}
+
+// PathValue returns the value of the named path variable, "" if the path has
+// none.
+func (r *request) PathValue(name string) string {
+	return r.vars[name]
+}
+
+// decode unmarshals the body into v. An empty body leaves v untouched.
+func (r *request) decode(v interface{}) error {
+	if len(r.body) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(r.body, v); err != nil {
+		return invalidArgument(err.Error())
+	}
+
+	return nil
+}
+
+// handler runs a command of an existing session and returns the value of the
+// response.
+type handler func(c *marionette.Client, r *request) (interface{}, error)
+
+// NewServer returns a Server whose sessions connect to Marionette on the given
+// host and port. Empty values select Marionette's defaults.
+func NewServer(host string, port int) *Server {
+	s := &Server{
+		host:     host,
+		port:     port,
+		sessions: make(map[string]*marionette.Client),
+	}
+
+	for _, e := range command.Endpoints {
+		var h http.HandlerFunc
+		switch e.Name {
+		case "New Session":
+			h = s.newSession
+		case "Delete Session":
+			h = s.deleteSession
+		case "Status":
+			h = s.status
+		default:
+			if _, found := handlers[e.Name]; !found {
+				continue
+			}
+
+			h = s.session(handlers[e.Name])
+		}
+
+		s.routes = append(s.routes, route{e.Method, strings.Split(e.Path, "/"), h})
+	}
+
+	return s
+}
+
+// ServeHTTP routes the request to the endpoint matching its path and method.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := strings.Split(strings.TrimSuffix(r.URL.Path, "/"), "/")
+	pathFound := false
+	for _, rt := range s.routes {
+		vars, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+
+		pathFound = true
+		if rt.method == r.Method {
+			rt.h(w, r.WithContext(context.WithValue(r.Context(), varsKey{}, vars)))
+			return
+		}
+	}
+
+	if pathFound {
+		writeError(w, &marionette.DriverError{ErrorType: "unknown method", Message: r.Method + " " + r.URL.Path})
+		return
+	}
+
+	writeError(w, &marionette.DriverError{ErrorType: "unknown command", Message: r.Method + " " + r.URL.Path})
+}
+
+type varsKey struct{}
+
+// match returns the path variables when segments match the route template.
+func (rt route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	vars := map[string]string{}
+	for i, seg := range rt.segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+
+			vars[seg[1:len(seg)-1]] = segments[i]
+			continue
+		}
+
+		if seg != segments[i] {
+			return nil, false
+		}
+	}
+
+	return vars, true
+}
+
+// Close deletes every session and closes their connections.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	sessions := s.sessions
+	s.sessions = make(map[string]*marionette.Client)
+	s.mu.Unlock()
+
+	var err error
+	for _, c := range sessions {
+		c.DeleteSession()
+		if cerr := c.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+func (s *Server) newSession(w http.ResponseWriter, r *http.Request) {
+	req, err := readRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	var body struct {
+		Capabilities marionette.CapabilitiesRequest `json:"capabilities"`
+	}
+
+	if err := req.decode(&body); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	c := marionette.NewClient()
+	if err := c.BindContext(r.Context()).Connect(s.host, s.port); err != nil {
+		writeError(w, &marionette.DriverError{ErrorType: "session not created", Message: err.Error()})
+		return
+	}
+
+	// a session created without a requested capability doesn't match the
+	// request: it is deleted and reported as "session not created".
+	caps, err := c.BindContext(r.Context()).StartSession(&body.Capabilities)
+	if err != nil {
+		if caps != nil {
+			c.BindContext(r.Context()).DeleteSession()
+		}
+
+		c.Close()
+		writeError(w, err)
+		return
+	}
+
+	if c.SessionID() == "" {
+		c.Close()
+		writeError(w, &marionette.DriverError{ErrorType: "session not created", Message: "Marionette returned no session id."})
+		return
+	}
+
+	s.mu.Lock()
+	s.sessions[c.SessionID()] = c
+	s.mu.Unlock()
+
+	writeValue(w, map[string]interface{}{"sessionId": c.SessionID(), "capabilities": caps})
+}
+
+func (s *Server) deleteSession(w http.ResponseWriter, r *http.Request) {
+	id := pathValue(r, "session_id")
+	s.mu.Lock()
+	c, found := s.sessions[id]
+	delete(s.sessions, id)
+	s.mu.Unlock()
+
+	if !found {
+		writeError(w, &marionette.DriverError{ErrorType: "invalid session id", Message: id})
+		return
+	}
+
+	err := c.BindContext(r.Context()).DeleteSession()
+	c.Close()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeValue(w, nil)
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	ready := len(s.sessions) == 0
+	s.mu.Unlock()
+
+	message := "ready"
+	if !ready {
+		message = "a session is already running"
+	}
+
+	writeValue(w, map[string]interface{}{"ready": ready, "message": message})
+}
+
+// session looks up the session of the request and runs h with its client,
+// bound to the request context so that a dropped request aborts the command.
+func (s *Server) session(h handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := pathValue(r, "session_id")
+		s.mu.Lock()
+		c, found := s.sessions[id]
+		s.mu.Unlock()
+
+		if !found {
+			writeError(w, &marionette.DriverError{ErrorType: "invalid session id", Message: id})
+			return
+		}
+
+		req, err := readRequest(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		v, err := h(c.BindContext(r.Context()), req)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		writeValue(w, v)
+	}
+}
+
+func readRequest(r *http.Request) (*request, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, invalidArgument(err.Error())
+	}
+
+	if len(body) != 0 && !json.Valid(body) {
+		return nil, invalidArgument("The request body is not valid JSON.")
+	}
+
+	vars, _ := r.Context().Value(varsKey{}).(map[string]string)
+	return &request{r, vars, body}, nil
+}
+
+func pathValue(r *http.Request, name string) string {
+	vars, _ := r.Context().Value(varsKey{}).(map[string]string)
+	return vars[name]
+}
+
+func invalidArgument(message string) error {
+	return &marionette.DriverError{ErrorType: "invalid argument", Message: message}
+}
+
+// status codes of the WebDriver errors, from the specification.
+var statusCodes = map[error]int{
+	marionette.ErrDetachedShadowRoot:      http.StatusNotFound,
+	marionette.ErrElementClickIntercepted: http.StatusBadRequest,
+	marionette.ErrElementNotInteractable:  http.StatusBadRequest,
+	marionette.ErrInsecureCertificate:     http.StatusBadRequest,
+	marionette.ErrInvalidArgument:         http.StatusBadRequest,
+	marionette.ErrInvalidCookieDomain:     http.StatusBadRequest,
+	marionette.ErrInvalidElementState:     http.StatusBadRequest,
+	marionette.ErrInvalidSelector:         http.StatusBadRequest,
+	marionette.ErrInvalidSessionID:        http.StatusNotFound,
+	marionette.ErrNoSuchAlert:             http.StatusNotFound,
+	marionette.ErrNoSuchCookie:            http.StatusNotFound,
+	marionette.ErrNoSuchElement:           http.StatusNotFound,
+	marionette.ErrNoSuchFrame:             http.StatusNotFound,
+	marionette.ErrNoSuchShadowRoot:        http.StatusNotFound,
+	marionette.ErrNoSuchWindow:            http.StatusNotFound,
+	marionette.ErrStaleElementReference:   http.StatusNotFound,
+	marionette.ErrUnknownCommand:          http.StatusNotFound,
+	marionette.ErrUnknownMethod:           http.StatusMethodNotAllowed,
+}
+
+// writeError writes err as a WebDriver error. Errors that don't come from
+// Marionette are reported as "unknown error".
+func writeError(w http.ResponseWriter, err error) {
+	de := &marionette.DriverError{ErrorType: "unknown error", Message: err.Error()}
+	var ce *marionette.CapabilityError
+	if errors.As(err, &ce) {
+		de.ErrorType = ce.Err.Error()
+	}
+
+	errors.As(err, &de)
+
+	status := http.StatusInternalServerError
+	if code := errors.Unwrap(de); code != nil {
+		if s, found := statusCodes[code]; found {
+			status = s
+		}
+	}
+
+	stacktrace := ""
+	if de.Stacktrace != nil {
+		stacktrace = *de.Stacktrace
+	}
+
+	value := map[string]interface{}{
+		"error":      de.ErrorType,
+		"message":    de.Message,
+		"stacktrace": stacktrace,
+	}
+
+	if de.Data != nil {
+		value["data"] = de.Data
+	}
+
+	write(w, status, value)
+}
+
+func writeValue(w http.ResponseWriter, v interface{}) {
+	write(w, http.StatusOK, v)
+}
+
+func write(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"value": v})
+}
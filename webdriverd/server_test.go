@@ -0,0 +1,176 @@
+package webdriverd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/njasm/marionette_client/marionettetest"
+)
+
+func do(t *testing.T, method string, url string, body string) (int, map[string]interface{}) {
+	req, err := http.NewRequest(method, url, bytes.NewBufferString(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var v map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+
+	return resp.StatusCode, v
+}
+
+func TestServer(t *testing.T) {
+	m := marionettetest.NewServer()
+	defer m.Close()
+	m.HandleValue("get", nil)
+	m.HandleValue("getTitle", "Example Domain")
+	m.HandleValue("findElement", marionettetest.Element("e1"))
+	m.HandleValue("getElementText", "More information...")
+	m.HandleValue("clickElement", nil)
+	m.HandleError("getTextFromDialog", "no such alert", "No modal dialog is currently open")
+
+	wd := NewServer(m.Host(), m.Port())
+	s := httptest.NewServer(wd)
+	defer s.Close()
+	defer wd.Close()
+
+	status, v := do(t, "POST", s.URL+"/session", `{"capabilities":{"alwaysMatch":{"acceptInsecureCerts":true}}}`)
+	if status != http.StatusOK {
+		t.Fatalf("new session failed: %v %v", status, v)
+	}
+
+	id := v["value"].(map[string]interface{})["sessionId"].(string)
+	session := s.URL + "/session/" + id
+
+	var p map[string]interface{}
+	m.Received("newSession")[0].Decode(&p)
+	if p["capabilities"].(map[string]interface{})["acceptInsecureCerts"] != true {
+		t.Fatalf("capabilities were not forwarded: %v", p)
+	}
+
+	status, v = do(t, "POST", s.URL+"/session", `{"capabilities":{"alwaysMatch":{"pageLoadStrategy":"fast"}}}`)
+	if e := v["value"].(map[string]interface{}); status != http.StatusBadRequest || e["error"] != "invalid argument" {
+		t.Fatalf("expected invalid capabilities to be refused, got %v %v", status, v)
+	}
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		status int
+		value  interface{}
+	}{
+		{"POST", "/url", `{"url":"http://example.com/"}`, 200, nil},
+		{"GET", "/title", ``, 200, "Example Domain"},
+		{"POST", "/element", `{"using":"css selector","value":"a"}`, 200, map[string]interface{}{marionettetest.ElementKey: "e1"}},
+		{"GET", "/element/e1/text", ``, 200, "More information..."},
+		{"POST", "/element/e1/click", `{}`, 200, nil},
+		{"POST", "/url", `{}`, 400, nil},
+		{"GET", "/nope", ``, 404, nil},
+		{"PUT", "/title", ``, 405, nil},
+	} {
+		status, v := do(t, tc.method, session+tc.path, tc.body)
+		if status != tc.status {
+			t.Errorf("%v %v: expected status %v, got %v %v", tc.method, tc.path, tc.status, status, v)
+			continue
+		}
+
+		if status == 200 {
+			got, _ := json.Marshal(v["value"])
+			want, _ := json.Marshal(tc.value)
+			if !bytes.Equal(got, want) {
+				t.Errorf("%v %v: expected %s, got %s", tc.method, tc.path, want, got)
+			}
+		}
+	}
+
+	var url map[string]string
+	m.Received("get")[0].Decode(&url)
+	if url["url"] != "http://example.com/" {
+		t.Fatalf("unexpected get parameters %v", url)
+	}
+
+	status, v = do(t, "GET", session+"/alert/text", ``)
+	e := v["value"].(map[string]interface{})
+	if status != http.StatusNotFound || e["error"] != "no such alert" || e["message"] != "No modal dialog is currently open" {
+		t.Fatalf("unexpected error response %v %v", status, v)
+	}
+
+	status, _ = do(t, "DELETE", session, ``)
+	if status != http.StatusOK {
+		t.Fatalf("delete session failed: %v", status)
+	}
+
+	status, v = do(t, "GET", session+"/title", ``)
+	e = v["value"].(map[string]interface{})
+	if status != http.StatusNotFound || e["error"] != "invalid session id" {
+		t.Fatalf("expected invalid session id, got %v %v", status, v)
+	}
+}
+
+func TestNewSessionDroppedCapability(t *testing.T) {
+	m := marionettetest.NewServer()
+	defer m.Close()
+
+	// Marionette creates the session without the requested strategy
+	m.Handle("newSession", func(c marionettetest.Command) (interface{}, error) {
+		return map[string]interface{}{
+			"sessionId": "s1",
+			"capabilities": map[string]interface{}{
+				"browserName":      "firefox",
+				"pageLoadStrategy": "normal",
+			},
+		}, nil
+	})
+
+	wd := NewServer(m.Host(), m.Port())
+	s := httptest.NewServer(wd)
+	defer s.Close()
+	defer wd.Close()
+
+	status, v := do(t, "POST", s.URL+"/session", `{"capabilities":{"alwaysMatch":{"pageLoadStrategy":"eager"}}}`)
+	e := v["value"].(map[string]interface{})
+	if status != http.StatusInternalServerError || e["error"] != "session not created" {
+		t.Fatalf("expected session not created, got %v %v", status, v)
+	}
+
+	if len(m.Received("deleteSession")) != 1 {
+		t.Fatal("expected the session to be deleted")
+	}
+}
+
+func TestTakeScreenshot(t *testing.T) {
+	m := marionettetest.NewServer()
+	defer m.Close()
+	m.HandleValue("takeScreenshot", "iVBORw0KGgo=")
+
+	wd := NewServer(m.Host(), m.Port())
+	s := httptest.NewServer(wd)
+	defer s.Close()
+	defer wd.Close()
+
+	_, v := do(t, "POST", s.URL+"/session", `{"capabilities":{}}`)
+	id := v["value"].(map[string]interface{})["sessionId"].(string)
+
+	status, v := do(t, "GET", s.URL+"/session/"+id+"/screenshot", ``)
+	if status != http.StatusOK || v["value"] != "iVBORw0KGgo=" {
+		t.Fatalf("unexpected screenshot response %v %v", status, v)
+	}
+
+	var p map[string]interface{}
+	m.Received("takeScreenshot")[0].Decode(&p)
+	if full, ok := p["full"]; !ok || full != false {
+		t.Fatalf("expected a viewport screenshot, got %v", p)
+	}
+}
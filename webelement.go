@@ -1,7 +1,9 @@
 package marionette_client
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 )
 
 type Point struct {
@@ -20,68 +22,139 @@ type ElementRect struct {
 }
 
 type WebElement struct {
-	id string //`json:"element-6066-11e4-a52e-4f735466cecf"`
-	c  *Client
+	id  string //`json:"element-6066-11e4-a52e-4f735466cecf"`
+	c   *Client
+	loc *locator // how the element was found, nil if it wasn't
 }
 
+// Id returns the web element reference of e, the one it was located again
+// with if it went stale.
 func (e *WebElement) Id() string {
+	if e.loc != nil {
+		if id := e.loc.current(); id != "" {
+			return id
+		}
+	}
+
 	return e.id
 }
 
-func (e *WebElement) FindElement(by By, value string) (*WebElement, error) {
-	return findElement(e.c, by, value, &e.id)
+// Client returns the client the commands of e are sent through.
+func (e *WebElement) Client() *Client {
+	return e.c
 }
 
-func (e *WebElement) FindElements(by By, value string) ([]*WebElement, error) {
-	return findElements(e.c, by, value, &e.id)
+// BindContext returns a copy of the element whose commands are sent with ctx.
+// See Client.BindContext. When the copy or e is located again, both refer to
+// the element found.
+func (e *WebElement) BindContext(ctx context.Context) *WebElement {
+	return &WebElement{id: e.Id(), c: e.c.BindContext(ctx), loc: e.loc}
 }
 
-func (e *WebElement) Enabled() bool {
-	return isElementEnabled(e.c, e.id)
+func (e *WebElement) FindElement(by By, value string) (found *WebElement, err error) {
+	err = e.do(func(id string) error {
+		found, err = findElement(e.c, by, value, e, id)
+		return err
+	})
+
+	return found, err
 }
 
-func (e *WebElement) Selected() bool {
-	return isElementSelected(e.c, e.id)
+func (e *WebElement) FindElements(by By, value string) (found []*WebElement, err error) {
+	err = e.do(func(id string) error {
+		found, err = findElements(e.c, by, value, e, id)
+		return err
+	})
+
+	return found, err
 }
 
-func (e *WebElement) Displayed() bool {
-	return isElementDisplayed(e.c, e.id)
+func (e *WebElement) Enabled() (enabled bool, err error) {
+	err = e.do(func(id string) error {
+		enabled, err = isElementEnabled(e.c, id)
+		return err
+	})
+
+	return enabled, err
 }
 
-func (e *WebElement) TagName() string {
-	return getElementTagName(e.c, e.id)
+func (e *WebElement) Selected() (selected bool, err error) {
+	err = e.do(func(id string) error {
+		selected, err = isElementSelected(e.c, id)
+		return err
+	})
+
+	return selected, err
 }
 
-func (e *WebElement) Text() string {
-	return getElementText(e.c, e.id)
+func (e *WebElement) Displayed() (displayed bool, err error) {
+	err = e.do(func(id string) error {
+		displayed, err = isElementDisplayed(e.c, id)
+		return err
+	})
+
+	return displayed, err
 }
 
-func (e *WebElement) Attribute(name string) string {
-	return getElementAttribute(e.c, e.id, name)
+func (e *WebElement) TagName() (name string, err error) {
+	err = e.do(func(id string) error {
+		name, err = getElementTagName(e.c, id)
+		return err
+	})
+
+	return name, err
 }
 
-func (e *WebElement) CssValue(property string) string {
-	return getElementCssPropertyValue(e.c, e.id, property)
+func (e *WebElement) Text() (text string, err error) {
+	err = e.do(func(id string) error {
+		text, err = getElementText(e.c, id)
+		return err
+	})
+
+	return text, err
 }
 
-func (e *WebElement) Rect() (*ElementRect, error) {
-	return getElementRect(e.c, e.id)
+func (e *WebElement) Attribute(name string) (value string, err error) {
+	err = e.do(func(id string) error {
+		value, err = getElementAttribute(e.c, id, name)
+		return err
+	})
+
+	return value, err
 }
 
-func (e *WebElement) Click() {
-	clickElement(e.c, e.id)
+func (e *WebElement) CssValue(property string) (value string, err error) {
+	err = e.do(func(id string) error {
+		value, err = getElementCssPropertyValue(e.c, id, property)
+		return err
+	})
+
+	return value, err
 }
 
-func (e *WebElement) SendKeys(keys string) {
-	sendKeysToElement(e.c, e.id, keys)
+func (e *WebElement) Rect() (rect *ElementRect, err error) {
+	err = e.do(func(id string) error {
+		rect, err = getElementRect(e.c, id)
+		return err
+	})
+
+	return rect, err
 }
 
-func (e *WebElement) Clear() {
-	clearElement(e.c, e.id)
+func (e *WebElement) Click() error {
+	return e.change(func(id string) error { return clickElement(e.c, id) })
+}
+
+func (e *WebElement) SendKeys(keys string) error {
+	return e.change(func(id string) error { return sendKeysToElement(e.c, id, keys) })
+}
+
+func (e *WebElement) Clear() error {
+	return e.change(func(id string) error { return clearElement(e.c, id) })
 }
 
 func (e *WebElement) Location() (x float32, y float32, err error) {
-	r, err := getElementRect(e.c, e.id)
+	r, err := e.Rect()
 	if err != nil {
 		return x, y, err
 	}
@@ -90,7 +163,7 @@ func (e *WebElement) Location() (x float32, y float32, err error) {
 }
 
 func (e *WebElement) Size() (w float32, h float32, err error) {
-	r, err := getElementRect(e.c, e.id)
+	r, err := e.Rect()
 	if err != nil {
 		return w, h, err
 	}
@@ -98,19 +171,46 @@ func (e *WebElement) Size() (w float32, h float32, err error) {
 	return r.Width, r.Height, nil
 }
 
-func (e *WebElement) Screenshot() (string, error) {
-	id := e.Id()
-	return takeScreenshot(e.c, &id)
+// Screenshot returns the takeScreenshot response, a base64 encoded PNG
+// wrapped in JSON. ScreenshotPNG and ScreenshotImage decode it.
+func (e *WebElement) Screenshot() (s string, err error) {
+	err = e.do(func(id string) error {
+		s, err = takeScreenshot(e.c, &id)
+		return err
+	})
+
+	return s, err
+}
+
+// MarshalJSON encodes e as a web element reference, so elements can be
+// passed as script arguments.
+func (e *WebElement) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{WEBDRIVER_ELEMENT_KEY: e.Id()})
 }
 
+// UnmarshalJSON decodes a web element reference, bare or wrapped in a
+// {"value": ...} response.
 func (e *WebElement) UnmarshalJSON(data []byte) error {
-	var d map[string]map[string]string
+	var d map[string]json.RawMessage
 	err := json.Unmarshal([]byte(data), &d)
 	if err != nil {
 		return err
 	}
 
-	e.id = d["value"][WEBDRIVER_ELEMENT_KEY]
+	if v, found := d["value"]; found {
+		if err := json.Unmarshal(v, &d); err != nil {
+			return err
+		}
+	}
+
+	id, found := d[WEBDRIVER_ELEMENT_KEY]
+	if !found {
+		id, found = d[LEGACY_ELEMENT_KEY]
+	}
+
+	if !found {
+		return fmt.Errorf("not a web element reference: %s", data)
+	}
 
-	return nil
+	return json.Unmarshal(id, &e.id)
 }